@@ -0,0 +1,59 @@
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock abstracts the time source used for token expiry and retry timers,
+// so that tests can control time instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Real returns the Clock backed by the time package
+func Real() Clock {
+	return realClock{}
+}
+
+// OrReal returns c, or the real clock if c is nil
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real()
+	}
+	return c
+}
+
+// Sleep waits for d on c, returning ctx.Err() early when ctx is done
+// On the real clock the timer is stopped on the way out, so cancelled waits don't hold it until it fires
+func Sleep(ctx context.Context, c Clock, d time.Duration) error {
+	if _, ok := c.(realClock); ok {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-OrReal(c).After(d):
+		return nil
+	}
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/dafanasiev/go-hms-push/push/config"
 	"github.com/dafanasiev/go-hms-push/push/core"
+	"github.com/dafanasiev/go-hms-push/push/model"
 )
 
 const (
@@ -54,7 +55,9 @@ var (
 	TargetTopic = "topic"
 
 	//TargetCondition the condition of the devices operated
-	TargetCondition = "'topic' in topics && ('topic' in topics || 'TopicC' in topics)"
+	TargetCondition = model.Condition().InTopic("topic").
+			And(model.Condition().InTopic("topic").Or().InTopic("TopicC")).
+			String()
 
 	//TargetToken the token of the device operated
 	TargetToken = token
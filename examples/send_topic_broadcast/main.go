@@ -0,0 +1,62 @@
+/*
+Copyright 2020. Huawei Technologies Co., Ltd. All rights reserved.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/examples/common"
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+// a broadcast of the latest weather: stale after an hour, and a newer one replaces it on offline devices
+const (
+	broadcastTTL         = time.Hour
+	broadcastCollapseKey = 1
+)
+
+func main() {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Printf("Panic! Error is %s\n", err)
+		}
+	}()
+
+	sendTopicBroadcast()
+}
+
+func sendTopicBroadcast() {
+	msgRequest := model.NewNotificationMsgRequest()
+	msgRequest.Message.Topic = common.TargetTopic
+	msgRequest.Message.Data = ""
+	msgRequest.Message.Android = model.GetDefaultAndroid().
+		WithTTL(broadcastTTL).
+		WithCollapseKey(broadcastCollapseKey)
+	msgRequest.Message.Android.Notification = model.GetDefaultAndroidNotification()
+
+	client := common.GetPushClient()
+
+	result, err := client.Send(context.Background(), msgRequest)
+	if err != nil {
+		fmt.Printf("Failed to send broadcast! Error is %s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("Succeed to send broadcast! Message id is %s\n", result.MessageId)
+}
@@ -1,3 +1,3 @@
 module github.com/dafanasiev/go-hms-push
 
-go 1.16
+go 1.21
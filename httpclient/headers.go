@@ -0,0 +1,93 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the response headers read by the PushResponse helpers
+const (
+	HeaderRequestId          = "X-Request-Id"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// RequestID returns the request id of the X-Request-Id header, or else the requestId of the json body
+func (r *PushResponse) RequestID() (string, bool) {
+	if id := strings.TrimSpace(r.Header.Get(HeaderRequestId)); id != "" {
+		return id, true
+	}
+
+	var body struct {
+		RequestId string `json:"requestId"`
+	}
+	if err := json.Unmarshal(r.Body, &body); err != nil || body.RequestId == "" {
+		return "", false
+	}
+	return body.RequestId, true
+}
+
+// RateLimitRemaining returns the requests left in the current rate limit window,
+// it reports false when the header is absent or isn't a non-negative integer
+func (r *PushResponse) RateLimitRemaining() (int, bool) {
+	value := strings.TrimSpace(r.Header.Get(HeaderRateLimitRemaining))
+	if value == "" {
+		return 0, false
+	}
+
+	remaining, err := strconv.Atoi(value)
+	if err != nil || remaining < 0 {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// RateLimitReset returns the time until the rate limit window resets, given in delay seconds
+// it reports false when the header is absent or malformed
+func (r *PushResponse) RateLimitReset() (time.Duration, bool) {
+	value := strings.TrimSpace(r.Header.Get(HeaderRateLimitReset))
+	if value == "" {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// RetryAfter returns the wait the Retry-After header asks for, see RetryAfterAt
+func (r *PushResponse) RetryAfter() (time.Duration, bool) {
+	return r.RetryAfterAt(time.Now())
+}
+
+// RetryAfterAt returns the wait the Retry-After header asks for as of now,
+// the header holds either delay seconds or an http date
+// It reports false when the header is absent or malformed, a date already past is a zero wait
+func (r *PushResponse) RetryAfterAt(now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(r.Header.Get(HeaderRetryAfter))
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := at.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
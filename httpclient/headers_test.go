@@ -0,0 +1,66 @@
+package httpclient_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/httpclient"
+)
+
+func TestResponseHeaders(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	requestID := func(r *httpclient.PushResponse) (interface{}, bool) { return r.RequestID() }
+	remaining := func(r *httpclient.PushResponse) (interface{}, bool) { return r.RateLimitRemaining() }
+	reset := func(r *httpclient.PushResponse) (interface{}, bool) { return r.RateLimitReset() }
+	retryAfter := func(r *httpclient.PushResponse) (interface{}, bool) { return r.RetryAfterAt(now) }
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		body   string
+		read   func(r *httpclient.PushResponse) (interface{}, bool)
+		want   interface{}
+		wantOK bool
+	}{
+		{"request id header", httpclient.HeaderRequestId, " req-1 ", `{"requestId":"body-1"}`, requestID, "req-1", true},
+		{"request id from body", "", "", `{"requestId":"body-1"}`, requestID, "body-1", true},
+		{"request id absent", "", "", `{"code":"80000000"}`, requestID, "", false},
+		{"request id malformed body", "", "", `not json`, requestID, "", false},
+
+		{"remaining", httpclient.HeaderRateLimitRemaining, "42", "", remaining, 42, true},
+		{"remaining zero", httpclient.HeaderRateLimitRemaining, "0", "", remaining, 0, true},
+		{"remaining absent", "", "", "", remaining, 0, false},
+		{"remaining non numeric", httpclient.HeaderRateLimitRemaining, "many", "", remaining, 0, false},
+		{"remaining negative", httpclient.HeaderRateLimitRemaining, "-1", "", remaining, 0, false},
+
+		{"reset", httpclient.HeaderRateLimitReset, "30", "", reset, 30 * time.Second, true},
+		{"reset absent", "", "", "", reset, time.Duration(0), false},
+		{"reset non numeric", httpclient.HeaderRateLimitReset, "soon", "", reset, time.Duration(0), false},
+		{"reset negative", httpclient.HeaderRateLimitReset, "-30", "", reset, time.Duration(0), false},
+
+		{"retry after seconds", httpclient.HeaderRetryAfter, "120", "", retryAfter, 2 * time.Minute, true},
+		{"retry after date", httpclient.HeaderRetryAfter, now.Add(90 * time.Second).Format(http.TimeFormat), "",
+			retryAfter, 90 * time.Second, true},
+		{"retry after past date", httpclient.HeaderRetryAfter, now.Add(-time.Hour).Format(http.TimeFormat), "",
+			retryAfter, time.Duration(0), true},
+		{"retry after absent", "", "", "", retryAfter, time.Duration(0), false},
+		{"retry after non numeric", httpclient.HeaderRetryAfter, "later", "", retryAfter, time.Duration(0), false},
+		{"retry after negative", httpclient.HeaderRetryAfter, "-5", "", retryAfter, time.Duration(0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &httpclient.PushResponse{Header: http.Header{}, Body: []byte(tt.body)}
+			if tt.header != "" {
+				resp.Header.Set(tt.header, tt.value)
+			}
+
+			got, ok := tt.read(resp)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
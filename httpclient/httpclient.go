@@ -18,18 +18,28 @@ package httpclient
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/dafanasiev/go-hms-push/clock"
+	"github.com/dafanasiev/go-hms-push/logging"
 	"github.com/dafanasiev/go-hms-push/push/config"
+	"github.com/dafanasiev/go-hms-push/push/constant"
 	"github.com/dafanasiev/go-hms-push/trace"
 )
 
@@ -38,34 +48,102 @@ type PushRequest struct {
 	URL    string
 	Body   []byte
 	Header []HTTPOption
+	// RefreshHeaders, if set, replaces Header before every retry attempt,
+	// e.g. to apply an access token refreshed since the previous attempt
+	RefreshHeaders func() []HTTPOption
 }
 
 type PushResponse struct {
 	Status int
 	Header http.Header
 	Body   []byte
+	// Code is the HMS result code of a json body, empty when the body has none
+	Code string
+	// Attempts is the number of attempts DoHttpRequest made to get this response
+	Attempts int
+	// AttemptDetails holds every attempt in order when HTTPClientConfig.RecordAttempts is set
+	AttemptDetails []Attempt
+}
+
+// Attempt is the outcome of one attempt of a request
+type Attempt struct {
+	// Status is zero for an attempt failing without a response
+	Status   int
+	Duration time.Duration
 }
 
 type HTTPTransportConfig struct {
 	ProxyUrl  *url.URL
 	TrustedCA string
+	// ClientCertificates are presented to servers asking for a client certificate
+	ClientCertificates []tls.Certificate
+	// ForceHTTP1 disables HTTP/2, which is otherwise negotiated through ALPN
+	ForceHTTP1 bool
+	// DialTimeout and KeepAlive configure the connection dialer, zero means 30s like Go's default transport
+	DialTimeout time.Duration
+	KeepAlive   time.Duration
 }
 
 type HTTPRetryConfig struct {
+	// MaxRetryTimes is the total number of attempts, zero means 1
 	MaxRetryTimes int
 	RetryInterval time.Duration
+	// RetryPolicy decides which responses get another attempt, nil means DefaultRetryPolicy
+	RetryPolicy RetryPolicy
 }
 
+// RetryPolicy reports whether a response is worth another attempt, its Code is already parsed
+// Transport errors are always retried
+type RetryPolicy func(resp *PushResponse) bool
+
 type HTTPClientConfig struct {
 	TransportConfig *HTTPTransportConfig
 	RetryConfig     *HTTPRetryConfig
+	Clock           clock.Clock
+	// Logger receives debug records of the retry decisions, nil discards them
+	Logger *slog.Logger
+	// Timeout is applied to http.Client.Timeout as a safety net for callers without a context deadline,
+	// the tightest of it and the context deadline wins, zero means no limit
+	Timeout time.Duration
+	// MaxConcurrentRequests bounds the number of in-flight requests, zero means no limit
+	MaxConcurrentRequests int
+	// RateLimit bounds the requests per second, every attempt counts, zero means no limit
+	RateLimit float64
+	// RecordAttempts fills PushResponse.AttemptDetails
+	RecordAttempts bool
 }
 
 type HTTPClient struct {
 	Client      *http.Client
 	RetryConfig *HTTPRetryConfig
+	clock       clock.Clock
+	// semaphore of in-flight requests, nil when unlimited
+	inflight chan struct{}
+	// nil when unlimited
+	limiter        *rateLimiter
+	recordAttempts bool
+	logger         *slog.Logger
 }
 
+var (
+	// ErrInvalidProxyURL is wrapped by the error returned for a malformed proxy url
+	ErrInvalidProxyURL = errors.New("parse proxy url error")
+	// ErrCATrustFileUnreadable is wrapped by the error returned when the trusted CA file can't be read
+	ErrCATrustFileUnreadable = errors.New("failed to read trusted CA file")
+	// ErrCAParseFailed is returned when the trusted CA file holds no valid PEM certificate,
+	// it's wrapped by the error of a corrupt gzipped file
+	ErrCAParseFailed = errors.New("failed to parse trusted CA certificate")
+	// ErrClientCertMismatch is wrapped by the error returned when the client certificate and key
+	// can't be loaded as a pair, e.g. the key doesn't match the certificate
+	ErrClientCertMismatch = errors.New("client certificate and key don't match")
+	// ErrAttemptTimeout is wrapped by the error of an attempt that ran out of HTTPClientConfig.Timeout,
+	// unlike a done request context it's retried
+	ErrAttemptTimeout = errors.New("attempt timed out")
+)
+
+const defaultDialTimeout = 30 * time.Second
+const defaultKeepAlive = 30 * time.Second
+
 type HTTPOption func(r *http.Request)
 
 func SetHeader(key string, value string) HTTPOption {
@@ -74,24 +152,68 @@ func SetHeader(key string, value string) HTTPOption {
 	}
 }
 
+// AddHeader appends a value to the header without replacing the existing ones
+func AddHeader(key string, value string) HTTPOption {
+	return func(r *http.Request) {
+		r.Header.Add(key, value)
+	}
+}
+
+// DelHeader removes every value of the header, including ones set by earlier options
+func DelHeader(key string) HTTPOption {
+	return func(r *http.Request) {
+		r.Header.Del(key)
+	}
+}
+
 func NewHTTPClientConfig(c *config.Config) (*HTTPClientConfig, error) {
 	if c == nil {
 		return nil, errors.New("config is nil")
 	}
 
+	if err := config.ValidateRetryConfig(c.MaxRetryTimes, c.RetryInterval); err != nil {
+		return nil, err
+	}
+
+	maxRetryTimes := c.MaxRetryTimes
+	if c.DisableRetry {
+		maxRetryTimes = 1
+	}
+
 	httpClientConfig := HTTPClientConfig{
 		RetryConfig: &HTTPRetryConfig{
-			MaxRetryTimes: c.MaxRetryTimes,
+			MaxRetryTimes: maxRetryTimes,
 			RetryInterval: c.RetryInterval,
 		},
+		Clock:                 c.Clock,
+		Logger:                c.Logger,
+		Timeout:               c.Timeout,
+		MaxConcurrentRequests: c.MaxConcurrentRequests,
+		RateLimit:             c.RateLimit,
+		RecordAttempts:        c.DetailedResults,
+	}
+
+	httpClientConfig.TransportConfig = &HTTPTransportConfig{
+		TrustedCA:   c.TrustedCA,
+		ForceHTTP1:  c.ForceHTTP1,
+		DialTimeout: c.DialTimeout,
+		KeepAlive:   c.KeepAlive,
+	}
+
+	if c.ClientCert != "" || c.ClientKey != "" {
+		certificate, err := loadClientCertificate(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		httpClientConfig.TransportConfig.ClientCertificates = []tls.Certificate{certificate}
 	}
 
 	if len(c.ProxyUrl) > 0 {
 		proxyURL, err := url.ParseRequestURI(c.ProxyUrl)
 		if err != nil {
-			return nil, fmt.Errorf("parse proxy url error: %w", err)
+			return nil, fmt.Errorf("%w: %s", ErrInvalidProxyURL, err)
 		}
-		httpClientConfig.TransportConfig = &HTTPTransportConfig{ProxyUrl: proxyURL, TrustedCA: c.TrustedCA}
+		httpClientConfig.TransportConfig.ProxyUrl = proxyURL
 	}
 
 	return &httpClientConfig, nil
@@ -99,58 +221,212 @@ func NewHTTPClientConfig(c *config.Config) (*HTTPClientConfig, error) {
 
 func NewHTTPClient(config *HTTPClientConfig) (*HTTPClient, error) {
 	var retryConfig *HTTPRetryConfig = nil
+	var clk clock.Clock = nil
+	var timeout time.Duration = 0
+	var inflight chan struct{} = nil
+	var rateLimit float64 = 0
+	var recordAttempts = false
+	var logger *slog.Logger = nil
+
+	dialer := net.Dialer{
+		Timeout:   defaultDialTimeout,
+		KeepAlive: defaultKeepAlive,
+	}
 
 	tr := http.Transport{
 		MaxIdleConns:       10,
 		IdleConnTimeout:    30 * time.Second,
 		DisableCompression: true,
 		TLSClientConfig:    &tls.Config{},
+		// a custom TLSClientConfig turns HTTP/2 off unless asked for explicitly
+		ForceAttemptHTTP2: true,
+		DialContext:       dialer.DialContext,
+		Proxy:             proxyFunc(nil),
 	}
 
 	if config != nil {
+		clk = config.Clock
+		logger = config.Logger
+
+		if config.Timeout < 0 {
+			return nil, errors.New("timeout can't be negative")
+		}
+		timeout = config.Timeout
+
+		if config.MaxConcurrentRequests < 0 {
+			return nil, errors.New("maximum concurrent requests can't be negative")
+		}
+		if config.MaxConcurrentRequests > 0 {
+			inflight = make(chan struct{}, config.MaxConcurrentRequests)
+		}
+
+		if config.RateLimit < 0 {
+			return nil, errors.New("rate limit can't be negative")
+		}
+		rateLimit = config.RateLimit
+		recordAttempts = config.RecordAttempts
+
 		if config.RetryConfig != nil {
-			if config.RetryConfig.MaxRetryTimes < 1 || config.RetryConfig.MaxRetryTimes > 5 {
-				return nil, errors.New("maximum retry times value cannot be less than 1 and more than 5")
+			rc, err := newRetryConfig(config.RetryConfig)
+			if err != nil {
+				return nil, err
 			}
-			retryConfig = config.RetryConfig
+			retryConfig = rc
 		}
 
 		if config.TransportConfig != nil {
 			if config.TransportConfig.ProxyUrl != nil {
-				tr.Proxy = http.ProxyURL(config.TransportConfig.ProxyUrl)
+				tr.Proxy = proxyFunc(config.TransportConfig.ProxyUrl)
+			}
+
+			if config.TransportConfig.DialTimeout < 0 || config.TransportConfig.KeepAlive < 0 {
+				return nil, errors.New("dial timeout and keep alive can't be negative")
+			}
+			if config.TransportConfig.DialTimeout > 0 {
+				dialer.Timeout = config.TransportConfig.DialTimeout
+			}
+			if config.TransportConfig.KeepAlive > 0 {
+				dialer.KeepAlive = config.TransportConfig.KeepAlive
+			}
+
+			if config.TransportConfig.ForceHTTP1 {
+				tr.ForceAttemptHTTP2 = false
+				tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
 			}
 
 			trustedCaPem := config.TransportConfig.TrustedCA
 			if trustedCaPem != "" {
-				bytes, err := ioutil.ReadFile(trustedCaPem)
+				rootCAs, err := loadTrustedCAs(trustedCaPem)
 				if err != nil {
 					return nil, err
 				}
 
-				rootCAs, _ := x509.SystemCertPool()
-				if rootCAs == nil {
-					rootCAs = x509.NewCertPool()
-				}
-				if ok := rootCAs.AppendCertsFromPEM(bytes); !ok {
-					return nil, errors.New("failed to parse trusted CA certificate")
-				}
-
 				tr.TLSClientConfig.RootCAs = rootCAs
 			}
+			tr.TLSClientConfig.Certificates = config.TransportConfig.ClientCertificates
 		}
 	}
 
 	if retryConfig == nil {
-		retryConfig = &HTTPRetryConfig{
-			MaxRetryTimes: 1,
-			RetryInterval: 0,
-		}
+		retryConfig, _ = newRetryConfig(&HTTPRetryConfig{})
 	}
 
-	httpClient := HTTPClient{Client: &http.Client{Transport: &tr}, RetryConfig: retryConfig}
+	httpClient := HTTPClient{
+		Client:      &http.Client{Transport: &tr, Timeout: timeout},
+		RetryConfig: retryConfig,
+		clock:       clock.OrReal(clk),
+		inflight:    inflight,
+
+		recordAttempts: recordAttempts,
+		logger:         logging.OrDiscard(logger),
+	}
+	if rateLimit > 0 {
+		httpClient.limiter = newRateLimiter(rateLimit, httpClient.clock)
+	}
 	return &httpClient, nil
 }
 
+// newRetryConfig returns a validated copy of rc with the defaults applied
+func newRetryConfig(rc *HTTPRetryConfig) (*HTTPRetryConfig, error) {
+	if err := config.ValidateRetryConfig(rc.MaxRetryTimes, rc.RetryInterval); err != nil {
+		return nil, err
+	}
+
+	c := *rc
+	if c.MaxRetryTimes == 0 {
+		c.MaxRetryTimes = config.DefaultMaxRetryTimes
+	}
+	return &c, nil
+}
+
+// loadClientCertificate loads the PEM certificate and key files of a client certificate
+func loadClientCertificate(certFile string, keyFile string) (tls.Certificate, error) {
+	if certFile == "" || keyFile == "" {
+		return tls.Certificate{}, errors.New("client certificate and key must be set together")
+	}
+
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read client certificate: %w", err)
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read client key: %w", err)
+	}
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("%w: %s", ErrClientCertMismatch, err)
+	}
+	return certificate, nil
+}
+
+// loadTrustedCAs returns the system cert pool extended with the certificates of path,
+// either a PEM file or a directory whose .pem and .crt files are all loaded
+func loadTrustedCAs(path string) (*x509.CertPool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCATrustFileUnreadable, err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCATrustFileUnreadable, err)
+		}
+
+		files = files[:0]
+		for _, entry := range entries {
+			ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(entry.Name(), ".gz")))
+			if !entry.IsDir() && (ext == ".pem" || ext == ".crt") {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	rootCAs, _ := x509.SystemCertPool()
+	if rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+
+	appended := false
+	for _, file := range files {
+		bytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCATrustFileUnreadable, err)
+		}
+		if bytes, err = gunzipPEM(bytes); err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrCAParseFailed, file, err)
+		}
+		if rootCAs.AppendCertsFromPEM(bytes) {
+			appended = true
+		}
+	}
+
+	if !appended {
+		return nil, ErrCAParseFailed
+	}
+	return rootCAs, nil
+}
+
+// gunzipPEM decompresses a gzipped CA bundle, recognized by the gzip magic bytes, and returns other input as is
+func gunzipPEM(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
 func (r *PushRequest) buildHTTPRequest() (*http.Request, error) {
 	var body io.Reader
 
@@ -170,24 +446,53 @@ func (r *PushRequest) buildHTTPRequest() (*http.Request, error) {
 	return req, nil
 }
 
-func (c *HTTPClient) doHttpRequest(ctx context.Context, req *PushRequest) (*PushResponse, error) {
+func (c *HTTPClient) doHttpRequest(ctx context.Context, req *PushRequest, attempt int) (*PushResponse, error) {
+	if c.limiter != nil {
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+
 	request, err := req.buildHTTPRequest()
 	if err != nil {
 		return nil, err
 	}
 
-	var tr trace.HmsTrace
-	if t := ctx.Value(trace.HmsTraceKey); t != nil {
-		tr = t.(trace.HmsTrace)
+	// the zero trace has no hooks to call
+	tr, _ := trace.FromContext(ctx)
+
+	if tr.GotMetadata != nil {
+		if md := trace.MetadataFrom(ctx); md != nil {
+			tr.GotMetadata(md)
+		}
 	}
 
 	if tr.GotRequestBody != nil {
 		tr.GotRequestBody(req.Body)
 	}
 
+	exchange := &trace.Exchange{
+		Method:        request.Method,
+		URL:           request.URL.String(),
+		RequestHeader: request.Header,
+		RequestBody:   req.Body,
+		Attempt:       attempt,
+	}
+	start := c.clock.Now()
 	resp, err := c.Client.Do(request.WithContext(ctx))
 
 	if err != nil {
+		err = attemptError(ctx, err)
+		if tr.GotExchange != nil {
+			exchange.Duration = c.clock.Now().Sub(start)
+			exchange.Err = err
+			tr.GotExchange(exchange)
+		}
 		return nil, err
 	}
 
@@ -195,10 +500,24 @@ func (c *HTTPClient) doHttpRequest(ctx context.Context, req *PushRequest) (*Push
 		tr.GotResponseStatus(resp.StatusCode)
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	if tr.GotResponseAttempt != nil {
+		tr.GotResponseAttempt(resp.StatusCode, attempt)
+	}
+
 	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if tr.GotExchange != nil {
+		exchange.Duration = c.clock.Now().Sub(start)
+		exchange.Status = resp.StatusCode
+		exchange.ResponseHeader = resp.Header
+		exchange.ResponseBody = body
+		if err != nil {
+			exchange.Err = attemptError(ctx, err)
+		}
+		tr.GotExchange(exchange)
+	}
 	if err != nil {
-		return nil, err
+		return nil, attemptError(ctx, err)
 	}
 
 	if tr.GotResponseBody != nil {
@@ -209,36 +528,184 @@ func (c *HTTPClient) doHttpRequest(ctx context.Context, req *PushRequest) (*Push
 		Status: resp.StatusCode,
 		Header: resp.Header,
 		Body:   body,
+		Code:   parseCode(body),
 	}, nil
 }
 
+func parseCode(body []byte) string {
+	var result struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return ""
+	}
+	return result.Code
+}
+
+// attemptError tells an attempt running out of its own timeout from one cancelled by its context
+func attemptError(ctx context.Context, err error) error {
+	var netErr net.Error
+	if ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %s", ErrAttemptTimeout, err)
+	}
+	return err
+}
+
+// RetryExhaustedError is returned when every attempt got a response the retry policy retries
+// It's only returned once MaxRetryTimes attempts were made, a ctx done before that yields its error instead
+type RetryExhaustedError struct {
+	Attempts int
+	// Statuses holds the status of each attempt in order, zero for an attempt failing without a response
+	Statuses []int
+	// LastResponse is the response of the final attempt
+	LastResponse *PushResponse
+}
+
+func (e *RetryExhaustedError) Error() string {
+	return fmt.Sprintf("all %d attempts failed with retryable responses, statuses %v", e.Attempts, e.Statuses)
+}
+
+// DefaultRetryPolicy retries 500, 502, 503 and 504 responses and the transient HMS internal error code
+func DefaultRetryPolicy(resp *PushResponse) bool {
+	return isRetryableStatus(resp.Status) || resp.Code == constant.CodeInternalError
+}
+
+// isRetryableStatus reports whether a response status is worth another attempt
+// 429 is left out on purpose, retrying a throttled request only deepens the throttling
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// DoHttpRequest sends the request, retrying transport errors and the responses of the retry policy as configured
+// An attempt running out of HTTPClientConfig.Timeout is a transport error, it's retried and counts against
+// MaxRetryTimes, the final error wraps ErrAttemptTimeout
+// A done ctx stops right away without retry, also while waiting between attempts, the final error then
+// wraps ctx.Err()
+// The response is nil whenever the error isn't: a response returned with a transport error would be
+// stale, the one of retries exhausted on retryable responses is in RetryExhaustedError.LastResponse
 func (c *HTTPClient) DoHttpRequest(ctx context.Context, req *PushRequest) (*PushResponse, error) {
 	var (
-		result *PushResponse
-		err    error
+		result   *PushResponse
+		err      error
+		statuses []int
+		details  []Attempt
 	)
 	for retryTimes := 0; retryTimes < c.RetryConfig.MaxRetryTimes; retryTimes++ {
-		result, err = c.doHttpRequest(ctx, req)
+		// a cancelled send makes no further attempt, not even a first one
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+			break
+		}
+
+		if retryTimes > 0 && req.RefreshHeaders != nil {
+			req.Header = req.RefreshHeaders()
+		}
+
+		start := c.clock.Now()
+		result, err = c.doHttpRequest(ctx, req, retryTimes+1)
 
+		status := 0
 		if err == nil {
-			break
+			status = result.Status
+		}
+		if c.recordAttempts {
+			details = append(details, Attempt{Status: status, Duration: c.clock.Now().Sub(start)})
 		}
 
-		if !c.pendingForRetry(ctx) {
+		if err == nil {
+			result.Attempts = retryTimes + 1
+			result.AttemptDetails = details
+			if !c.retryPolicy()(result) {
+				return result, nil
+			}
+		}
+		statuses = append(statuses, status)
+
+		if retryTimes+1 >= c.RetryConfig.MaxRetryTimes {
 			break
 		}
+		if logging.Debug(ctx, c.logger) {
+			c.logRetry(ctx, req, retryTimes+1, result, err)
+		}
+		if !c.pendingForRetry(ctx) {
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+			}
+			return nil, fmt.Errorf("%w: retry stopped after %d attempts, statuses %v", ctx.Err(), len(statuses), statuses)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// every attempt was made, a single one keeps its response as is as there was no retry to exhaust
+	if len(statuses) > 1 {
+		return nil, &RetryExhaustedError{Attempts: len(statuses), Statuses: statuses, LastResponse: result}
 	}
-	return result, err
+	return result, nil
 }
 
+// logRetry logs the decision to retry an attempt, the request headers and body are left out
+// as they carry the access token and the app secret
+func (c *HTTPClient) logRetry(ctx context.Context, req *PushRequest, attempt int, resp *PushResponse, err error) {
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL),
+		slog.Int("attempt", attempt),
+		slog.Duration("interval", c.RetryConfig.RetryInterval),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		attrs = append(attrs, slog.Int("status", resp.Status), slog.String("code", resp.Code))
+	}
+	c.logger.LogAttrs(ctx, slog.LevelDebug, "hms push: retrying request", attrs...)
+}
+
+func (c *HTTPClient) retryPolicy() RetryPolicy {
+	if c.RetryConfig.RetryPolicy != nil {
+		return c.RetryConfig.RetryPolicy
+	}
+	return DefaultRetryPolicy
+}
+
+// RateLimit returns the requests per second the client is limited to, zero when unlimited
+func (c *HTTPClient) RateLimit() float64 {
+	if c.limiter == nil {
+		return 0
+	}
+	return float64(time.Second) / float64(c.limiter.interval)
+}
+
+// acquire waits for an in-flight request slot, giving up when ctx is done
+func (c *HTTPClient) acquire(ctx context.Context) error {
+	if c.inflight == nil {
+		return nil
+	}
+
+	select {
+	case c.inflight <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *HTTPClient) release() {
+	if c.inflight != nil {
+		<-c.inflight
+	}
+}
+
+// pendingForRetry waits for the retry interval, it reports false when ctx is done first
 func (c *HTTPClient) pendingForRetry(ctx context.Context) bool {
 	if c.RetryConfig.RetryInterval > 0 {
-		select {
-		case <-ctx.Done():
-			return false
-		case <-time.After(c.RetryConfig.RetryInterval):
-			return true
-		}
+		return clock.Sleep(ctx, c.clock, c.RetryConfig.RetryInterval) == nil
 	}
-	return false
+	return ctx.Err() == nil
 }
@@ -0,0 +1,505 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/httpclient"
+	"github.com/dafanasiev/go-hms-push/push/config"
+	"github.com/dafanasiev/go-hms-push/trace"
+)
+
+func newTestClient(t *testing.T, c *config.Config) *httpclient.HTTPClient {
+	t.Helper()
+
+	cfg, err := httpclient.NewHTTPClientConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := httpclient.NewHTTPClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func unavailableServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDoHttpRequestCancelledDuringRetryWait(t *testing.T) {
+	server := unavailableServer(t)
+	client := newTestClient(t, &config.Config{MaxRetryTimes: 3, RetryInterval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	resp, err := client.DoHttpRequest(ctx, &httpclient.PushRequest{Method: http.MethodPost, URL: server.URL})
+	if resp != nil {
+		t.Errorf("response = %+v, want nil", resp)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+}
+
+func TestDoHttpRequestRetryExhausted(t *testing.T) {
+	server := unavailableServer(t)
+	client := newTestClient(t, &config.Config{MaxRetryTimes: 3, RetryInterval: time.Millisecond})
+
+	_, err := client.DoHttpRequest(context.Background(), &httpclient.PushRequest{Method: http.MethodPost, URL: server.URL})
+	var exhausted *httpclient.RetryExhaustedError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("error = %v, want *RetryExhaustedError", err)
+	}
+	if exhausted.Attempts != 3 || exhausted.LastResponse == nil || exhausted.LastResponse.Status != http.StatusServiceUnavailable {
+		t.Errorf("exhausted = %+v, want 3 attempts ending with a 503", exhausted)
+	}
+}
+
+// sequenceServer answers the attempts with statuses in order, repeating the last one, and counts them
+func sequenceServer(t *testing.T, statuses ...int) (*httptest.Server, *int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		status := statuses[min(attempts, len(statuses)-1)]
+		attempts++
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &attempts
+}
+
+func TestDoHttpRequestMixedOutcomes(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantStatus   int
+		wantAttempts int
+	}{
+		{"success after a failure", []int{http.StatusServiceUnavailable, http.StatusOK}, http.StatusOK, 2},
+		{"success after failures", []int{http.StatusBadGateway, http.StatusInternalServerError, http.StatusOK}, http.StatusOK, 3},
+		{"non retryable after a failure", []int{http.StatusServiceUnavailable, http.StatusBadRequest}, http.StatusBadRequest, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, attempts := sequenceServer(t, tt.statuses...)
+			client := newTestClient(t, &config.Config{MaxRetryTimes: 3, RetryInterval: time.Millisecond})
+
+			resp, err := client.DoHttpRequest(context.Background(), &httpclient.PushRequest{Method: http.MethodPost, URL: server.URL})
+			if err != nil {
+				t.Fatalf("error = %v, want the response of the last attempt", err)
+			}
+			if resp.Status != tt.wantStatus || *attempts != tt.wantAttempts {
+				t.Errorf("status = %d after %d attempts, want %d after %d", resp.Status, *attempts, tt.wantStatus, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestDoHttpRequestRetryExhaustedStatuses(t *testing.T) {
+	statuses := []int{http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	server, _ := sequenceServer(t, statuses...)
+	client := newTestClient(t, &config.Config{MaxRetryTimes: 3, RetryInterval: time.Millisecond})
+
+	_, err := client.DoHttpRequest(context.Background(), &httpclient.PushRequest{Method: http.MethodPost, URL: server.URL})
+	var exhausted *httpclient.RetryExhaustedError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("error = %v, want *RetryExhaustedError", err)
+	}
+	if !reflect.DeepEqual(exhausted.Statuses, statuses) || exhausted.Attempts != 3 {
+		t.Errorf("exhausted = %+v, want statuses %v", exhausted, statuses)
+	}
+	if exhausted.LastResponse == nil || exhausted.LastResponse.Status != http.StatusGatewayTimeout {
+		t.Errorf("last response = %+v, want the 504", exhausted.LastResponse)
+	}
+}
+
+// slowServer stalls the first slow attempts past any test timeout and answers the later ones right away
+func slowServer(t *testing.T, slow int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= int32(slow) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &attempts
+}
+
+func TestDoHttpRequestAttemptTimeoutIsRetried(t *testing.T) {
+	server, attempts := slowServer(t, 1)
+	client := newTestClient(t, &config.Config{MaxRetryTimes: 3, RetryInterval: time.Millisecond, Timeout: 50 * time.Millisecond})
+
+	resp, err := client.DoHttpRequest(context.Background(), &httpclient.PushRequest{Method: http.MethodGet, URL: server.URL})
+	if err != nil {
+		t.Fatalf("error = %v, want the timed out attempt retried", err)
+	}
+	if resp.Status != http.StatusOK || resp.Attempts != 2 || attempts.Load() != 2 {
+		t.Errorf("status = %d after %d attempts (%d served), want 200 after 2", resp.Status, resp.Attempts, attempts.Load())
+	}
+}
+
+func TestDoHttpRequestAttemptTimeoutExhaustsRetries(t *testing.T) {
+	server, attempts := slowServer(t, 10)
+	client := newTestClient(t, &config.Config{MaxRetryTimes: 3, RetryInterval: time.Millisecond, Timeout: 50 * time.Millisecond})
+
+	resp, err := client.DoHttpRequest(context.Background(), &httpclient.PushRequest{Method: http.MethodGet, URL: server.URL})
+	if !errors.Is(err, httpclient.ErrAttemptTimeout) {
+		t.Fatalf("error = %v, want ErrAttemptTimeout", err)
+	}
+	if resp != nil {
+		t.Errorf("response = %+v, want nil with the error", resp)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want every timed out attempt counted against MaxRetryTimes 3", got)
+	}
+}
+
+func TestDoHttpRequestDeadlineBeforeRetryBudget(t *testing.T) {
+	server := unavailableServer(t)
+	client := newTestClient(t, &config.Config{MaxRetryTimes: 5, RetryInterval: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.DoHttpRequest(ctx, &httpclient.PushRequest{Method: http.MethodPost, URL: server.URL})
+	var exhausted *httpclient.RetryExhaustedError
+	if errors.As(err, &exhausted) {
+		t.Fatalf("error = %v, want no *RetryExhaustedError before MaxRetryTimes attempts", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestDoHttpRequestCancelledMidRequest(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	client := newTestClient(t, &config.Config{MaxRetryTimes: 3, RetryInterval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.DoHttpRequest(ctx, &httpclient.PushRequest{Method: http.MethodPost, URL: server.URL})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled request returned after %v, want it aborted promptly", elapsed)
+	}
+}
+
+func TestDoHttpRequestCancelledRetryWaitLeaksNothing(t *testing.T) {
+	server := unavailableServer(t)
+	client := newTestClient(t, &config.Config{MaxRetryTimes: 3, RetryInterval: time.Hour})
+	request := &httpclient.PushRequest{Method: http.MethodPost, URL: server.URL}
+
+	// a first request opens the keep-alive connection, whose goroutines outlive the requests
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	_, _ = client.DoHttpRequest(ctx, request)
+	cancel()
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		start := time.Now()
+		if _, err := client.DoHttpRequest(ctx, request); !errors.Is(err, context.Canceled) {
+			t.Fatalf("error = %v, want context.Canceled", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("cancelled retry wait returned after %v, want it to stop promptly", elapsed)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines = %d after the cancelled requests, %d before", after, before)
+	}
+}
+
+// writePEM writes the blocks to a new file of the test temp dir and returns its path
+func writePEM(t *testing.T, name string, blocks ...*pem.Block) string {
+	t.Helper()
+
+	var data []byte
+	for _, block := range blocks {
+		data = append(data, pem.EncodeToMemory(block)...)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newKeyPair returns a self-signed certificate and its key as PEM blocks
+func newKeyPair(t *testing.T) (*pem.Block, *pem.Block) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pem.Block{Type: "CERTIFICATE", Bytes: der}, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}
+}
+
+func TestNewHTTPClientConfigSentinelErrors(t *testing.T) {
+	cert, key := newKeyPair(t)
+	_, otherKey := newKeyPair(t)
+	certFile := writePEM(t, "cert.pem", cert)
+
+	tests := []struct {
+		name string
+		conf *config.Config
+		want error
+	}{
+		{"invalid proxy url", &config.Config{ProxyUrl: "not a url"}, httpclient.ErrInvalidProxyURL},
+		{"mismatched client key", &config.Config{ClientCert: certFile, ClientKey: writePEM(t, "key.pem", otherKey)},
+			httpclient.ErrClientCertMismatch},
+		{"key as certificate", &config.Config{ClientCert: writePEM(t, "key.pem", key), ClientKey: writePEM(t, "key.pem", key)},
+			httpclient.ErrClientCertMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := httpclient.NewHTTPClientConfig(tt.conf)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientSentinelErrors(t *testing.T) {
+	cert, _ := newKeyPair(t)
+
+	tests := []struct {
+		name      string
+		trustedCA string
+		want      error
+	}{
+		{"missing CA file", filepath.Join(t.TempDir(), "missing.pem"), httpclient.ErrCATrustFileUnreadable},
+		{"CA file without certificate", writePEM(t, "empty.pem"), httpclient.ErrCAParseFailed},
+		{"CA file with a key", writePEM(t, "key.pem", &pem.Block{Type: "EC PRIVATE KEY", Bytes: cert.Bytes}),
+			httpclient.ErrCAParseFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := httpclient.NewHTTPClientConfig(&config.Config{TrustedCA: tt.trustedCA})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = httpclient.NewHTTPClient(cfg); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// writeFiles writes the files to a new directory and returns it
+func writeFiles(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewHTTPClientTrustedCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	corrupt := append([]byte{0x1f, 0x8b}, "not gzip"...)
+
+	tests := []struct {
+		name      string
+		trustedCA func(t *testing.T) string
+		wantErr   error
+	}{
+		{"PEM file", func(t *testing.T) string {
+			return filepath.Join(writeFiles(t, map[string][]byte{"ca.pem": caPEM}), "ca.pem")
+		}, nil},
+		{"gzipped PEM file", func(t *testing.T) string {
+			return filepath.Join(writeFiles(t, map[string][]byte{"ca.pem.gz": gzipped(t, caPEM)}), "ca.pem.gz")
+		}, nil},
+		// only the .pem and .crt files are loaded, gzipped or not
+		{"directory", func(t *testing.T) string {
+			return writeFiles(t, map[string][]byte{"ca.crt.gz": gzipped(t, caPEM), "README": []byte("not a certificate")})
+		}, nil},
+		{"corrupt gzip", func(t *testing.T) string {
+			return filepath.Join(writeFiles(t, map[string][]byte{"ca.pem.gz": corrupt}), "ca.pem.gz")
+		}, httpclient.ErrCAParseFailed},
+		{"directory with a corrupt gzip", func(t *testing.T) string {
+			return writeFiles(t, map[string][]byte{"ca.pem": caPEM, "other.pem.gz": corrupt})
+		}, httpclient.ErrCAParseFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := httpclient.NewHTTPClientConfig(&config.Config{TrustedCA: tt.trustedCA(t), MaxRetryTimes: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := httpclient.NewHTTPClient(cfg)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the server certificate is only trusted through the loaded CA
+			resp, err := client.DoHttpRequest(context.Background(), &httpclient.PushRequest{Method: http.MethodGet, URL: server.URL})
+			if err != nil || resp.Status != http.StatusOK {
+				t.Errorf("request = %+v, %v, want the server trusted", resp, err)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientConfigClientCertificate(t *testing.T) {
+	cert, key := newKeyPair(t)
+
+	cfg, err := httpclient.NewHTTPClientConfig(&config.Config{
+		ClientCert: writePEM(t, "cert.pem", cert),
+		ClientKey:  writePEM(t, "key.pem", key),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certificates := cfg.TransportConfig.ClientCertificates; len(certificates) != 1 {
+		t.Errorf("client certificates = %d, want 1", len(certificates))
+	}
+
+	_, err = httpclient.NewHTTPClientConfig(&config.Config{ClientCert: writePEM(t, "cert.pem", cert)})
+	if err == nil {
+		t.Error("a client certificate without its key was accepted")
+	}
+}
+
+// stepClock moves forward by step every time it's read
+type stepClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+func (c *stepClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func TestExchangeDurationUsesTheClock(t *testing.T) {
+	server := unavailableServer(t)
+	client := newTestClient(t, &config.Config{
+		MaxRetryTimes: 1,
+		Clock:         &stepClock{now: time.Unix(1700000000, 0), step: time.Hour},
+	})
+
+	var exchanges []*trace.Exchange
+	ctx := trace.NewContext(context.Background(), trace.HmsTrace{
+		GotExchange: func(exchange *trace.Exchange) { exchanges = append(exchanges, exchange) },
+	})
+	_, _ = client.DoHttpRequest(ctx, &httpclient.PushRequest{Method: http.MethodPost, URL: server.URL})
+
+	if len(exchanges) != 1 {
+		t.Fatalf("exchanges = %d, want 1", len(exchanges))
+	}
+	if d := exchanges[0].Duration; d != time.Hour {
+		t.Errorf("duration = %s, want the hour the clock moved", d)
+	}
+}
@@ -0,0 +1,32 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type proxyKey struct{}
+
+// WithProxy returns a context whose requests go through proxyURL instead of the configured proxy
+// Connections are pooled per proxy, so spreading traffic over many proxies means more
+// connection setups and TLS handshakes than a single proxy would take
+func WithProxy(ctx context.Context, proxyURL *url.URL) context.Context {
+	return context.WithValue(ctx, proxyKey{}, proxyURL)
+}
+
+// ProxyFrom returns the proxy set on ctx with WithProxy, nil if there is none
+func ProxyFrom(ctx context.Context) *url.URL {
+	proxyURL, _ := ctx.Value(proxyKey{}).(*url.URL)
+	return proxyURL
+}
+
+// proxyFunc picks the proxy of the request context, falling back to the configured one
+func proxyFunc(configured *url.URL) func(*http.Request) (*url.URL, error) {
+	return func(r *http.Request) (*url.URL, error) {
+		if proxyURL := ProxyFrom(r.Context()); proxyURL != nil {
+			return proxyURL, nil
+		}
+		return configured, nil
+	}
+}
@@ -0,0 +1,45 @@
+package httpclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/clock"
+)
+
+// rateLimiter spaces requests evenly to stay under a number of requests per second
+type rateLimiter struct {
+	interval time.Duration
+	clock    clock.Clock
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newRateLimiter(perSecond float64, clk clock.Clock) *rateLimiter {
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		clock:    clk,
+	}
+}
+
+// wait blocks until the caller may send, giving up when ctx is done
+// The slot of a request giving up isn't handed back
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := l.clock.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	d := at.Sub(now)
+	if d <= 0 {
+		return nil
+	}
+
+	return clock.Sleep(ctx, l.clock, d)
+}
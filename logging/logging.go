@@ -0,0 +1,35 @@
+// Package logging holds the optional diagnostic logger shared by the clients
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discard = slog.New(discardHandler{})
+
+// Discard returns the logger dropping every record
+func Discard() *slog.Logger {
+	return discard
+}
+
+// OrDiscard returns l, or the discarding logger if l is nil
+func OrDiscard(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return discard
+	}
+	return l
+}
+
+// Debug reports whether l logs debug records, call sites check it before building their attributes
+// so that a discarding logger costs no allocation
+func Debug(ctx context.Context, l *slog.Logger) bool {
+	return l.Enabled(ctx, slog.LevelDebug)
+}
@@ -17,23 +17,65 @@ Copyright 2020. Huawei Technologies Co., Ltd. All rights reserved.
 package auth
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
+	"github.com/dafanasiev/go-hms-push/clock"
 	"github.com/dafanasiev/go-hms-push/httpclient"
+	"github.com/dafanasiev/go-hms-push/logging"
 	"github.com/dafanasiev/go-hms-push/push/config"
+	"github.com/dafanasiev/go-hms-push/trace"
 )
 
 type AuthClient struct {
-	endpoint  string
-	appId     string
-	appSecret string
-	client    *httpclient.HTTPClient
+	endpoint      string
+	revokeUrl     string
+	grantType     string
+	scope         string
+	appId         string
+	appSecret     string
+	client        *httpclient.HTTPClient
+	debug         bool
+	requestHook   func(form url.Values, header http.Header)
+	tokenCheck    func(token string) error
+	clock         clock.Clock
+	expiryMargin  time.Duration
+	refreshJitter time.Duration
+	minInterval   time.Duration
+	waitInterval  bool
+	grace         time.Duration
+	logger        *slog.Logger
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	token     string
+	expiresAt time.Time
+	refreshAt time.Time
+	// time of the last fetch attempt, successful or not
+	fetchedAt time.Time
+	// a background refresh is running
+	refreshing bool
+	// number of tokens stored, telling a waiting refresh that another caller fetched meanwhile
+	stored int
 }
 
+const defaultGrantType = "client_credentials"
+
+// ErrInvalidAccessToken is wrapped by the *AuthError of a fetched access token failing the checks,
+// see config.Config.AccessTokenCheck
+var ErrInvalidAccessToken = errors.New("the auth endpoint returned an invalid access token")
+
 type TokenMsg struct {
 	AccessToken      string `json:"access_token"`
 	ExpiresIn        int    `json:"expires_in"`
@@ -42,6 +84,35 @@ type TokenMsg struct {
 	ErrorDescription string `json:"error_description"`
 }
 
+// TokenInfo is an access token along with where it came from
+type TokenInfo struct {
+	Token string
+	// FromCache is false when the token was fetched from the auth endpoint for this call
+	FromCache bool
+	// ExpiresAt is zero when the auth endpoint stated no lifetime
+	ExpiresAt time.Time
+}
+
+// AuthError reports a failure to obtain an access token from the auth endpoint,
+// as opposed to a failure of the request that needed the token
+type AuthError struct {
+	// StatusCode and Body are the auth endpoint response, StatusCode is zero when there was none
+	StatusCode int
+	Body       []byte
+	Err        error
+}
+
+func (e *AuthError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("failed to get access token: %s", e.Err)
+	}
+	return fmt.Sprintf("failed to get access token: status %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
 // NewClient creates a instance of the huawei cloud auth client
 // It's contained in huawei cloud app and provides service through huawei cloud app
 // If AuthUrl is null using default auth url address
@@ -54,51 +125,380 @@ func NewAuthClient(conf *config.Config) (*AuthClient, error) {
 	if err != nil {
 		return nil, err
 	}
+	// the rate limit is meant for the push quota
+	httpClientCfg.RateLimit = 0
 
 	c, err := httpclient.NewHTTPClient(httpClientCfg)
 	if err != nil {
-		return nil, errors.New("failed to get http client")
+		return nil, fmt.Errorf("failed to get http client: %w", err)
 	}
 
-	if conf.AuthUrl == "" {
-		return nil, errors.New("authUrl can't be empty")
+	endpoint, err := conf.AuthURL()
+	if err != nil {
+		return nil, err
 	}
 
 	return &AuthClient{
-		endpoint:  conf.AuthUrl,
-		appId:     conf.AppId,
-		appSecret: conf.AppSecret,
-		client:    c,
+		endpoint:      endpoint,
+		revokeUrl:     conf.RevokeUrl,
+		grantType:     conf.GrantType,
+		scope:         conf.TokenScope,
+		appId:         conf.AppId,
+		appSecret:     conf.AppSecret,
+		client:        c,
+		debug:         conf.DebugAuth,
+		requestHook:   conf.TokenRequestHook,
+		tokenCheck:    conf.AccessTokenCheck,
+		clock:         clock.OrReal(conf.Clock),
+		expiryMargin:  conf.ExpiryMargin,
+		refreshJitter: conf.RefreshJitter,
+		minInterval:   conf.MinRefreshInterval,
+		waitInterval:  conf.WaitRefreshInterval,
+		grace:         conf.ExpiryGrace,
+		logger:        logging.OrDiscard(conf.Logger),
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
 	}, nil
 }
 
-// GetAuthToken gets token from huawei cloud
-// the developer can access the app by using this token
-func (ac *AuthClient) GetAuthToken(ctx context.Context) (string, error) {
-	if ac.appId == "" || ac.appSecret == "" {
-		return "", errors.New("appId or appSecret is null")
+// Token returns the cached access token, fetching a new one when there is none
+// or the cached one expires within the configured expiry margin and jitter
+func (ac *AuthClient) Token(ctx context.Context) (string, error) {
+	info, err := ac.TokenWithInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	return info.Token, nil
+}
+
+// TokenWithInfo is Token also telling whether the token came from the cache, e.g. for cache hit metrics
+func (ac *AuthClient) TokenWithInfo(ctx context.Context) (*TokenInfo, error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	now := ac.clock.Now()
+	if ac.token != "" && (ac.refreshAt.IsZero() || now.Before(ac.refreshAt)) {
+		return ac.infoLocked(true), nil
+	}
+
+	if ac.grace > 0 && ac.token != "" && now.Before(ac.expiresAt.Add(ac.grace)) {
+		if !ac.refreshing && !now.Before(ac.fetchedAt.Add(ac.minInterval)) {
+			ac.refreshing = true
+			go ac.backgroundRefresh(backgroundContext(ctx))
+		}
+		return ac.infoLocked(true), nil
+	}
+	return ac.refreshLocked(ctx)
+}
+
+// backgroundRefresh fetches a token without holding the lock, so sends keep getting the cached one meanwhile
+// A failure leaves the cached token, sends wait on a refresh of their own once the grace is over
+func (ac *AuthClient) backgroundRefresh(ctx context.Context) {
+	now := ac.clock.Now()
+	token, err := ac.fetchToken(ctx, trace.TokenRefreshBackground)
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.refreshing = false
+	ac.fetchedAt = now
+	if err != nil {
+		if logging.Debug(ctx, ac.logger) {
+			ac.logger.LogAttrs(ctx, slog.LevelDebug, "hms push: background token refresh failed",
+				slog.String("error", err.Error()))
+		}
+		return
+	}
+	ac.storeLocked(ctx, now, token)
+}
+
+// backgroundContext keeps the trace hooks of the request starting a background refresh but neither its
+// metadata nor its cancellation, the refresh outlives the request and isn't done on its behalf
+func backgroundContext(ctx context.Context) context.Context {
+	bg := context.Background()
+	if t, ok := trace.FromContext(ctx); ok {
+		bg = trace.NewContext(bg, t)
+	}
+	return bg
+}
+
+// Refresh fetches a new access token and replaces the cached one
+func (ac *AuthClient) Refresh(ctx context.Context) (string, error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	info, err := ac.refreshLocked(ctx)
+	if err != nil {
+		return "", err
+	}
+	return info.Token, nil
+}
+
+// Invalidate drops the cached access token, the next Token call fetches a new one
+func (ac *AuthClient) Invalidate() {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	// fetchedAt is kept, so invalidating doesn't bypass MinRefreshInterval
+	ac.token = ""
+	ac.expiresAt = time.Time{}
+	ac.refreshAt = time.Time{}
+
+	if logging.Debug(context.Background(), ac.logger) {
+		ac.logger.LogAttrs(context.Background(), slog.LevelDebug, "hms push: access token invalidated")
 	}
-	body := fmt.Sprintf("grant_type=client_credentials&client_secret=%s&client_id=%s", ac.appSecret, ac.appId)
+}
 
+// RevokeToken revokes the cached access token at the configured revoke endpoint and drops it,
+// so the next Token call fetches a new one
+// The revocation form carries the token with the app credentials, client_id and client_secret
+// Without a revoke endpoint the token is only dropped, a revocation failure is returned as *AuthError
+func (ac *AuthClient) RevokeToken(ctx context.Context) error {
+	ac.mu.Lock()
+	token := ac.token
+	ac.token = ""
+	ac.expiresAt = time.Time{}
+	ac.refreshAt = time.Time{}
+	ac.mu.Unlock()
+
+	if ac.revokeUrl == "" || token == "" {
+		return nil
+	}
+
+	// the revoke endpoint authenticates the app like the token endpoint does
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("client_id", ac.appId)
+	form.Set("client_secret", ac.appSecret)
 	request := &httpclient.PushRequest{
 		Method: http.MethodPost,
-		URL:    ac.endpoint,
-		Body:   []byte(body),
+		URL:    ac.revokeUrl,
+		Body:   []byte(form.Encode()),
 		Header: []httpclient.HTTPOption{httpclient.SetHeader("Content-Type", "application/x-www-form-urlencoded")},
 	}
 
-	resp, err := ac.client.DoHttpRequest(ctx, request)
+	resp, err := ac.client.DoHttpRequest(ac.traceContext(ctx), request)
+	if err != nil {
+		return &AuthError{Err: err}
+	}
+
+	if resp.Status != http.StatusOK {
+		return &AuthError{StatusCode: resp.Status, Body: resp.Body}
+	}
+	return nil
+}
+
+// refreshLocked fetches a token once MinRefreshInterval allows it
+// The lock is released while waiting out the interval, so cache hits and Invalidate aren't held up
+func (ac *AuthClient) refreshLocked(ctx context.Context) (*TokenInfo, error) {
+	for ac.minInterval > 0 {
+		wait := ac.fetchedAt.Add(ac.minInterval).Sub(ac.clock.Now())
+		if wait <= 0 {
+			break
+		}
+		if ac.token != "" && !ac.waitInterval {
+			return ac.infoLocked(true), nil
+		}
+
+		stored := ac.stored
+		ac.mu.Unlock()
+		err := clock.Sleep(ctx, ac.clock, wait)
+		ac.mu.Lock()
+		if err != nil {
+			return nil, &AuthError{Err: err}
+		}
+
+		// a token fetched by another caller meanwhile is as fresh as this fetch would be
+		if ac.token != "" && ac.stored != stored {
+			return ac.infoLocked(true), nil
+		}
+	}
+
+	now := ac.clock.Now()
+	ac.fetchedAt = now
+	token, err := ac.fetchToken(ctx, trace.TokenRefreshOnDemand)
+	if err != nil {
+		return nil, err
+	}
+
+	ac.storeLocked(ctx, now, token)
+	return ac.infoLocked(false), nil
+}
+
+// fetchToken fetches a token for the cache, marking the fetch with what triggered it, see trace.TokenRefreshKey
+func (ac *AuthClient) fetchToken(ctx context.Context, refresh string) (*TokenMsg, error) {
+	ctx = trace.WithMetadata(ctx, trace.Metadata{trace.TokenRefreshKey: refresh})
+	token, err := ac.getTokenMsg(ctx)
+	if t, ok := trace.FromContext(ctx); ok && t.TokenRefreshed != nil {
+		t.TokenRefreshed(trace.MetadataFrom(ctx), err)
+	}
+	return token, err
+}
+
+// storeLocked caches a token fetched at now
+// Without expiry options the token is kept until it's invalidated or a send reports it expired,
+// like the client always did, the stated expiry is only reported by TokenWithInfo then
+func (ac *AuthClient) storeLocked(ctx context.Context, now time.Time, token *TokenMsg) {
+	ac.token = token.AccessToken
+	ac.stored++
+	ac.expiresAt = time.Time{}
+	ac.refreshAt = time.Time{}
+	// a token without a stated lifetime is kept until it's invalidated
+	if token.ExpiresIn > 0 {
+		ac.expiresAt = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+		if ac.refreshesOnExpiry() {
+			ac.refreshAt = ac.expiresAt.Add(-ac.expiryMargin - ac.jitter())
+		}
+	}
+
+	// the token itself is never logged
+	if logging.Debug(ctx, ac.logger) {
+		ac.logger.LogAttrs(ctx, slog.LevelDebug, "hms push: access token refreshed",
+			slog.Time("expires_at", ac.expiresAt), slog.Time("refresh_at", ac.refreshAt))
+	}
+}
+
+// refreshesOnExpiry tells whether the token is refreshed ahead of its stated expiry,
+// which takes one of ExpiryMargin, RefreshJitter and ExpiryGrace
+func (ac *AuthClient) refreshesOnExpiry() bool {
+	return ac.expiryMargin > 0 || ac.refreshJitter > 0 || ac.grace > 0
+}
+
+func (ac *AuthClient) infoLocked(fromCache bool) *TokenInfo {
+	return &TokenInfo{Token: ac.token, FromCache: fromCache, ExpiresAt: ac.expiresAt}
+}
+
+func (ac *AuthClient) jitter() time.Duration {
+	if ac.refreshJitter <= 0 {
+		return 0
+	}
+	return time.Duration(ac.rand.Int63n(int64(ac.refreshJitter)))
+}
+
+// GetAuthToken gets token from huawei cloud
+// the developer can access the app by using this token
+// Failures to get the token are returned as *AuthError
+func (ac *AuthClient) GetAuthToken(ctx context.Context) (string, error) {
+	token, err := ac.getTokenMsg(ctx)
 	if err != nil {
 		return "", err
 	}
+	return token.AccessToken, nil
+}
+
+func (ac *AuthClient) getTokenMsg(ctx context.Context) (*TokenMsg, error) {
+	if ac.appId == "" || ac.appSecret == "" {
+		return nil, errors.New("appId or appSecret is null")
+	}
+
+	request := ac.getTokenRequest()
+
+	resp, err := ac.client.DoHttpRequest(ac.traceContext(ctx), request)
+	if err != nil {
+		authErr := &AuthError{Err: err}
+		var exhausted *httpclient.RetryExhaustedError
+		if errors.As(err, &exhausted) && exhausted.LastResponse != nil {
+			authErr.StatusCode = exhausted.LastResponse.Status
+			authErr.Body = exhausted.LastResponse.Body
+		}
+		return nil, authErr
+	}
+
+	if resp.Status != http.StatusOK {
+		return nil, &AuthError{StatusCode: resp.Status, Body: resp.Body}
+	}
 
 	var token TokenMsg
-	if resp.Status == 200 {
-		err = json.Unmarshal(resp.Body, &token)
-		if err != nil {
-			return "", err
+	err = json.Unmarshal(resp.Body, &token)
+	if err != nil {
+		return nil, &AuthError{StatusCode: resp.Status, Body: resp.Body, Err: err}
+	}
+
+	if strings.TrimSpace(token.AccessToken) == "" {
+		return nil, &AuthError{StatusCode: resp.Status, Body: resp.Body, Err: fmt.Errorf("%w: empty", ErrInvalidAccessToken)}
+	}
+	if err = ac.checkToken(token.AccessToken); err != nil {
+		// the body carries the rejected token, it's left out
+		return nil, &AuthError{StatusCode: resp.Status, Err: fmt.Errorf("%w: %s", ErrInvalidAccessToken, err)}
+	}
+	return &token, nil
+}
+
+// checkToken tells whether a fetched token can go in an Authorization header, unless the config checks it
+func (ac *AuthClient) checkToken(token string) error {
+	if ac.tokenCheck != nil {
+		return ac.tokenCheck(token)
+	}
+
+	for _, r := range token {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return errors.New("whitespace or control characters")
+		}
+	}
+	return nil
+}
+
+func (ac *AuthClient) getTokenRequest() *httpclient.PushRequest {
+	grantType := ac.grantType
+	if grantType == "" {
+		grantType = defaultGrantType
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", grantType)
+	form.Set("client_secret", ac.appSecret)
+	form.Set("client_id", ac.appId)
+	if ac.scope != "" {
+		form.Set("scope", ac.scope)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if ac.requestHook != nil {
+		ac.requestHook(form, header)
+	}
+
+	options := make([]httpclient.HTTPOption, 0, len(header))
+	for key, values := range header {
+		options = append(options, httpclient.DelHeader(key))
+		for _, value := range values {
+			options = append(options, httpclient.AddHeader(key, value))
+		}
+	}
+
+	return &httpclient.PushRequest{
+		Method: http.MethodPost,
+		URL:    ac.endpoint,
+		Body:   []byte(form.Encode()),
+		Header: options,
+	}
+}
+
+// traceContext keeps the app secret away from the trace hooks of ctx:
+// the auth request is traced with the secret masked in debug mode and not traced otherwise
+func (ac *AuthClient) traceContext(ctx context.Context) context.Context {
+	t, ok := trace.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	if !ac.debug {
+		return trace.NewContext(ctx, trace.HmsTrace{})
+	}
+
+	secret := []byte(url.QueryEscape(ac.appSecret))
+	masked := []byte(url.QueryEscape(trace.MaskSecret(ac.appSecret)))
+	if gotRequestBody := t.GotRequestBody; gotRequestBody != nil {
+		t.GotRequestBody = func(body []byte) {
+			gotRequestBody(bytes.ReplaceAll(body, secret, masked))
+		}
+	}
+	if gotExchange := t.GotExchange; gotExchange != nil {
+		t.GotExchange = func(exchange *trace.Exchange) {
+			maskedExchange := *exchange
+			maskedExchange.RequestBody = bytes.ReplaceAll(exchange.RequestBody, secret, masked)
+			gotExchange(&maskedExchange)
 		}
-		return token.AccessToken, nil
 	}
-	return "", nil
+	return trace.NewContext(ctx, t)
 }
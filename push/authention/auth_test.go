@@ -0,0 +1,535 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	auth "github.com/dafanasiev/go-hms-push/push/authention"
+	"github.com/dafanasiev/go-hms-push/push/config"
+	"github.com/dafanasiev/go-hms-push/push/pushtest"
+)
+
+func newAuthClient(t *testing.T, conf *config.Config) *auth.AuthClient {
+	t.Helper()
+
+	client, err := auth.NewAuthClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// tokenForms fetches a token with the config and returns the forms of the auth requests the server received
+func tokenForms(t *testing.T, configure func(conf *config.Config)) []url.Values {
+	t.Helper()
+
+	server := pushtest.NewServer()
+	defer server.Close()
+
+	conf := server.Config()
+	configure(conf)
+	if _, err := newAuthClient(t, conf).Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var forms []url.Values
+	for _, r := range server.Requests() {
+		form, err := url.ParseQuery(string(r.Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		forms = append(forms, form)
+	}
+	return forms
+}
+
+func TestTokenRequestGrantTypeAndScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		grantType string
+		scope     string
+		wantGrant string
+	}{
+		{"defaults", "", "", "client_credentials"},
+		{"scope", "", "push.send", "client_credentials"},
+		{"grant type", "urn:example:grant", "", "urn:example:grant"},
+		{"grant type and scope", "urn:example:grant", "push.send push.topic", "urn:example:grant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forms := tokenForms(t, func(conf *config.Config) {
+				conf.GrantType = tt.grantType
+				conf.TokenScope = tt.scope
+			})
+			if len(forms) != 1 {
+				t.Fatalf("auth requests = %d, want 1", len(forms))
+			}
+
+			form := forms[0]
+			if got := form.Get("grant_type"); got != tt.wantGrant {
+				t.Errorf("grant_type = %q, want %q", got, tt.wantGrant)
+			}
+			if _, ok := form["scope"]; ok != (tt.scope != "") || form.Get("scope") != tt.scope {
+				t.Errorf("scope = %q, want %q", form["scope"], tt.scope)
+			}
+			if form.Get("client_id") != pushtest.AppId || form.Get("client_secret") != pushtest.AppSecret {
+				t.Errorf("credentials missing from %v", form)
+			}
+		})
+	}
+}
+
+func TestTokenWithInfoCacheHits(t *testing.T) {
+	server := pushtest.NewServer()
+	defer server.Close()
+	client := newAuthClient(t, server.Config())
+
+	first, err := client.TokenWithInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.FromCache || first.Token == "" || first.ExpiresAt.IsZero() {
+		t.Errorf("first token = %+v, want a fetched one", first)
+	}
+
+	for i := 0; i < 3; i++ {
+		info, err := client.TokenWithInfo(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.FromCache || info.Token != first.Token || !info.ExpiresAt.Equal(first.ExpiresAt) {
+			t.Errorf("token %d = %+v, want the cached %+v", i, info, first)
+		}
+	}
+	if token, err := client.Token(context.Background()); err != nil || token != first.Token {
+		t.Errorf("Token = %q, %v, want the cached %q", token, err, first.Token)
+	}
+
+	if server.TokensIssued() != 1 {
+		t.Errorf("tokens issued = %d, want cache hits not to reach the auth endpoint", server.TokensIssued())
+	}
+
+	client.Invalidate()
+	if info, err := client.TokenWithInfo(context.Background()); err != nil || info.FromCache {
+		t.Errorf("token after Invalidate = %+v, %v, want a fetched one", info, err)
+	}
+	if server.TokensIssued() != 2 {
+		t.Errorf("tokens issued = %d, want 2", server.TokensIssued())
+	}
+}
+
+// manualClock is a clock only moving when told, its timers never fire
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *manualClock) After(time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// slowAuthServer answers the first token request right away and blocks the next ones until release is closed
+func slowAuthServer(t *testing.T, release <-chan struct{}) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := fetches.Add(1)
+		if n > 1 {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":60}`, n)
+	}))
+	t.Cleanup(server.Close)
+	return server, &fetches
+}
+
+func TestExpiryGraceWithSlowAuthEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	server, fetches := slowAuthServer(t, release)
+	clk := &manualClock{now: time.Unix(1700000000, 0)}
+
+	client := newAuthClient(t, &config.Config{
+		AppId:         pushtest.AppId,
+		AppSecret:     pushtest.AppSecret,
+		AuthUrl:       server.URL,
+		MaxRetryTimes: 1,
+		ExpiryGrace:   time.Minute,
+		Clock:         clk,
+	})
+	if _, err := client.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(61 * time.Second)
+
+	// the sends keep the expired token while the refresh hangs on the auth endpoint
+	const sends = 20
+	errs := make(chan error, sends)
+	var wg sync.WaitGroup
+	for i := 0; i < sends; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := client.TokenWithInfo(context.Background())
+			if err == nil && (info.Token != "token-1" || !info.FromCache) {
+				err = fmt.Errorf("token = %+v, want the cached token-1", info)
+			}
+			errs <- err
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		close(release)
+		t.Fatal("sends waited on the slow refresh")
+	}
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		token, err := client.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token == "token-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("token = %q, want the refreshed token-2", token)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("token fetches = %d, want a single background refresh for the concurrent sends", n)
+	}
+}
+
+func TestExpiryGraceDisabledWaitsOnRefresh(t *testing.T) {
+	release := make(chan struct{})
+	server, fetches := slowAuthServer(t, release)
+	clk := &manualClock{now: time.Unix(1700000000, 0)}
+
+	client := newAuthClient(t, &config.Config{
+		AppId:         pushtest.AppId,
+		AppSecret:     pushtest.AppSecret,
+		AuthUrl:       server.URL,
+		MaxRetryTimes: 1,
+		ExpiryMargin:  time.Second,
+		Clock:         clk,
+	})
+	if _, err := client.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(61 * time.Second)
+
+	tokens := make(chan string, 1)
+	go func() {
+		token, _ := client.Token(context.Background())
+		tokens <- token
+	}()
+
+	select {
+	case token := <-tokens:
+		t.Fatalf("token = %q returned before the refresh finished", token)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if token := <-tokens; token != "token-2" {
+		t.Errorf("token = %q, want the refreshed token-2", token)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("token fetches = %d, want 2", n)
+	}
+}
+
+func TestTokenKeptPastExpiryWithoutExpiryOptions(t *testing.T) {
+	server := pushtest.NewServer()
+	defer server.Close()
+	clk := &manualClock{now: time.Unix(1700000000, 0)}
+
+	conf := server.Config()
+	conf.Clock = clk
+	client := newAuthClient(t, conf)
+
+	first, err := client.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pushtest tokens are stated to expire after an hour
+	clk.Advance(2 * time.Hour)
+
+	info, err := client.TokenWithInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.FromCache || info.Token != first || server.TokensIssued() != 1 {
+		t.Errorf("token = %+v, tokens issued = %d, want the first token kept until invalidated", info, server.TokensIssued())
+	}
+}
+
+func TestTokenWithoutLifetimeIsCachedUntilInvalidated(t *testing.T) {
+	for _, expiresIn := range []int{0, -1} {
+		var fetches atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := fetches.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":%d}`, n, expiresIn)
+		}))
+		clk := &manualClock{now: time.Unix(1700000000, 0)}
+
+		client := newAuthClient(t, &config.Config{
+			AppId:         pushtest.AppId,
+			AppSecret:     pushtest.AppSecret,
+			AuthUrl:       server.URL,
+			MaxRetryTimes: 1,
+			// expiry options make the stated lifetime count
+			ExpiryMargin: time.Minute,
+			Clock:        clk,
+		})
+
+		for i := 0; i < 3; i++ {
+			info, err := client.TokenWithInfo(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Token != "token-1" || !info.ExpiresAt.IsZero() {
+				t.Errorf("expires_in %d: token = %+v, want the cached token-1 without expiry", expiresIn, info)
+			}
+			clk.Advance(time.Hour)
+		}
+
+		client.Invalidate()
+		if token, err := client.Token(context.Background()); err != nil || token != "token-2" {
+			t.Errorf("expires_in %d: token after Invalidate = %q, %v, want token-2", expiresIn, token, err)
+		}
+		server.Close()
+	}
+}
+
+func TestInvalidAccessTokenIsNotCached(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"whitespace only", " \t "},
+		{"inner space", "pushtest token"},
+		{"newline", "pushtest-token\r\nX-Injected: 1"},
+		{"control character", "pushtest-\x00token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the first fetch returns the invalid token, the later ones a valid one
+			var fetches atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				token := tt.token
+				if fetches.Add(1) > 1 {
+					token = "valid-token"
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": token, "expires_in": 3600})
+			}))
+			defer server.Close()
+
+			client := newAuthClient(t, &config.Config{
+				AppId:         pushtest.AppId,
+				AppSecret:     pushtest.AppSecret,
+				AuthUrl:       server.URL,
+				MaxRetryTimes: 1,
+			})
+
+			_, err := client.Token(context.Background())
+			var authErr *auth.AuthError
+			if !errors.As(err, &authErr) || !errors.Is(err, auth.ErrInvalidAccessToken) {
+				t.Fatalf("error = %v, want an *AuthError wrapping ErrInvalidAccessToken", err)
+			}
+
+			info, err := client.TokenWithInfo(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.FromCache || info.Token != "valid-token" || fetches.Load() != 2 {
+				t.Errorf("token = %+v after %d fetches, want the invalid token left uncached", info, fetches.Load())
+			}
+		})
+	}
+}
+
+// gatedClock is a manualClock whose timers fire when the test says, telling it when one is waited on
+type gatedClock struct {
+	manualClock
+	waiting chan struct{}
+	fire    chan time.Time
+}
+
+func (c *gatedClock) After(time.Duration) <-chan time.Time {
+	c.waiting <- struct{}{}
+	return c.fire
+}
+
+func TestRefreshWaitsOutTheIntervalWithoutTheLock(t *testing.T) {
+	server := pushtest.NewServer()
+	defer server.Close()
+	clk := &gatedClock{manualClock: manualClock{now: time.Unix(1700000000, 0)},
+		waiting: make(chan struct{}), fire: make(chan time.Time)}
+
+	conf := server.Config()
+	conf.Clock = clk
+	conf.MinRefreshInterval = time.Minute
+	conf.WaitRefreshInterval = true
+	client := newAuthClient(t, conf)
+	if _, err := client.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed := make(chan string, 1)
+	go func() {
+		token, err := client.Refresh(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		refreshed <- token
+	}()
+	<-clk.waiting
+
+	// the refresh waiting out the interval doesn't hold up the cache hits
+	cached := make(chan *auth.TokenInfo, 1)
+	go func() {
+		info, err := client.TokenWithInfo(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		cached <- info
+	}()
+	select {
+	case info := <-cached:
+		if !info.FromCache || info.Token != "pushtest-token-1" {
+			t.Errorf("token = %+v while the refresh waits, want the cached pushtest-token-1", info)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("TokenWithInfo blocked on the waiting refresh")
+	}
+
+	clk.Advance(time.Minute)
+	clk.fire <- clk.Now()
+	if token := <-refreshed; token != "pushtest-token-2" {
+		t.Errorf("refreshed token = %q, want pushtest-token-2", token)
+	}
+}
+
+func TestWaitingRefreshesShareTheFetch(t *testing.T) {
+	server := pushtest.NewServer()
+	defer server.Close()
+	clk := &gatedClock{manualClock: manualClock{now: time.Unix(1700000000, 0)},
+		waiting: make(chan struct{}), fire: make(chan time.Time)}
+
+	conf := server.Config()
+	conf.Clock = clk
+	conf.MinRefreshInterval = time.Minute
+	client := newAuthClient(t, conf)
+	if _, err := client.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	client.Invalidate()
+
+	// without a cached token both callers wait out the interval
+	tokens := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			token, err := client.Token(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			tokens <- token
+		}()
+		<-clk.waiting
+	}
+
+	clk.Advance(time.Minute)
+	clk.fire <- clk.Now()
+	clk.fire <- clk.Now()
+	for i := 0; i < 2; i++ {
+		if token := <-tokens; token != "pushtest-token-2" {
+			t.Errorf("token = %q, want the one fetch pushtest-token-2", token)
+		}
+	}
+	if server.TokensIssued() != 2 {
+		t.Errorf("tokens issued = %d, want the waiting callers to share a fetch", server.TokensIssued())
+	}
+}
+
+func TestRevokeTokenForm(t *testing.T) {
+	server := pushtest.NewServer()
+	defer server.Close()
+
+	var forms []url.Values
+	revoke := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		forms = append(forms, r.PostForm)
+	}))
+	defer revoke.Close()
+
+	conf := server.Config()
+	conf.RevokeUrl = revoke.URL
+	client := newAuthClient(t, conf)
+	token, err := client.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = client.RevokeToken(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []url.Values{{"token": {token}, "client_id": {pushtest.AppId}, "client_secret": {pushtest.AppSecret}}}
+	if !reflect.DeepEqual(forms, want) {
+		t.Errorf("revoke forms = %v, want %v", forms, want)
+	}
+
+	// the token is dropped, the next one is fetched
+	if token, err = client.Token(context.Background()); err != nil || token != "pushtest-token-2" {
+		t.Errorf("token after RevokeToken = %q, %v, want pushtest-token-2", token, err)
+	}
+}
@@ -0,0 +1,22 @@
+package auth
+
+import "context"
+
+// TokenSource supplies the access tokens of the push requests
+// AuthClient is the implementation fetching them from HMS, tests can stub it with StaticTokenSource
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+var _ TokenSource = (*AuthClient)(nil)
+
+type staticTokenSource string
+
+func (s staticTokenSource) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// StaticTokenSource returns a TokenSource always returning token, e.g. to test sends without an auth endpoint
+func StaticTokenSource(token string) TokenSource {
+	return staticTokenSource(token)
+}
@@ -16,15 +16,115 @@ Copyright 2020. Huawei Technologies Co., Ltd. All rights reserved.
 
 package config
 
-import "time"
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/clock"
+)
+
+// DefaultDataSizeWarningThreshold is the data size warning threshold a zero DataSizeWarningThreshold stands for
+const DefaultDataSizeWarningThreshold = 0.8
 
 type Config struct {
-	AppId         string
-	AppSecret     string
-	AuthUrl       string
-	PushUrl       string
-	ProxyUrl      string
-	TrustedCA     string
+	AppId     string
+	AppSecret string
+	// StrictCredentials tightens the secret format ValidateCredentials accepts
+	StrictCredentials bool
+	AuthUrl           string
+	// GrantType is the OAuth grant of the token request, empty means client_credentials
+	GrantType string
+	// TokenScope is sent as the scope of the token request when set
+	TokenScope string
+	// RevokeUrl is the access token revocation endpoint, empty means RevokeToken only drops the cached token
+	RevokeUrl string
+	PushUrl   string
+	// PathTemplate overrides the send path appended to PushUrl, it must contain the {appId} placeholder
+	// Empty means constant.DefaultSendPathTemplate
+	PathTemplate string
+	// StatsPathTemplate is the message statistics path appended to PushUrl, it must contain the {appId} placeholder
+	// HMS only offers statistics on some tiers, so there is no default and QueryMessageStatus fails when empty
+	StatsPathTemplate string
+	ProxyUrl          string
+	// TrustedCA is a PEM file or a directory of .pem and .crt files, either can be gzipped
+	TrustedCA string
+	// ClientCert and ClientKey are the PEM files of a client certificate for mutual TLS,
+	// e.g. with a gateway requiring one, they're set together
+	ClientCert string
+	ClientKey  string
+	// ForceHTTP1 disables HTTP/2, for proxies or gateways misbehaving with it
+	ForceHTTP1 bool
+	// DialTimeout and KeepAlive configure the connection dialer, zero means 30s like Go's default transport
+	DialTimeout time.Duration
+	KeepAlive   time.Duration
+	// MaxRetryTimes is the total number of attempts of a request, 1 to 5, so 1 means no retry
+	// Zero means 1
 	MaxRetryTimes int
+	// RetryInterval is the wait between attempts, zero retries right away
 	RetryInterval time.Duration
+	// DisableRetry makes every request a single attempt, whatever MaxRetryTimes says
+	DisableRetry bool
+	// Timeout bounds every single http attempt, zero means no limit
+	// It applies together with context deadlines, whichever expires first wins: an attempt running out of
+	// Timeout is retried, while a done context stops the request without retry
+	Timeout time.Duration
+	// DefaultDeadline bounds the client calls, retries and token refreshes included, made with a context
+	// without a deadline, e.g. context.Background(), zero means no default
+	// Each send of a batch gets its own
+	DefaultDeadline time.Duration
+	// MaxConcurrentRequests bounds the number of in-flight http requests, zero means no limit
+	MaxConcurrentRequests int
+	// RateLimit bounds the push requests per second, zero means no limit
+	RateLimit float64
+	// DetailedResults fills SendResult.TotalDuration and SendResult.Attempts, it's off to save the bookkeeping
+	DetailedResults bool
+	// StrictValidation makes sends fail on the contradictions of model.Message.Conflicts
+	// instead of leaving them to Validate warnings, and on template placeholders without a variable
+	StrictValidation bool
+	// DataSizeWarningThreshold is the fraction of constant.MaxDataSize above which a send warns its data payload
+	// nears the limit, through the logger and the DataSizeWarning trace hook
+	// Zero means DefaultDataSizeWarningThreshold, a negative value disables the warning
+	DataSizeWarningThreshold float64
+
+	// AuthorizationProvider, if set, returns the whole Authorization header value of every push request,
+	// replacing the built-in token fetching, AppSecret and AuthUrl aren't needed then
+	AuthorizationProvider func(ctx context.Context) (string, error)
+	// TokenRequestHook, if set, can adjust the form parameters and headers of the access token request
+	// before it's sent, e.g. for regional endpoints expecting extra parameters
+	// The form holds grant_type, client_id and client_secret of the HMS OAuth flow
+	TokenRequestHook func(form url.Values, header http.Header)
+	// AccessTokenCheck, if set, replaces the format check of the fetched access tokens, which rejects
+	// whitespace and control characters; an empty or blank token is rejected whatever it says
+	AccessTokenCheck func(token string) error
+	// DebugAuth passes the auth requests to the HmsTrace hooks with AppSecret masked,
+	// otherwise the auth requests aren't traced at all
+	DebugAuth bool
+
+	// ExpiryMargin makes the cached access token refresh that long before its stated expiry
+	// Without ExpiryMargin, RefreshJitter or ExpiryGrace the token is kept until a send reports it expired
+	ExpiryMargin time.Duration
+	// RefreshJitter brings the token refresh forward by a random duration up to that long,
+	// so that instances sharing the same token lifetime don't refresh all at once
+	RefreshJitter time.Duration
+	// MinRefreshInterval is the least time between two access token fetches, protecting the auth quota
+	// A refresh asked for sooner returns the cached token, or waits out the interval when there is none
+	// or WaitRefreshInterval is set
+	MinRefreshInterval  time.Duration
+	WaitRefreshInterval bool
+	// ExpiryGrace keeps serving the cached access token up to that long past its expiry while a background
+	// refresh runs, instead of making sends wait on a slow auth endpoint
+	// Zero means sends wait on the refresh
+	ExpiryGrace time.Duration
+	// TokenInvalidationThreshold drops the cached access token after that many consecutive sends
+	// rejected for authorization reasons, so that a token revoked before its expiry gets replaced
+	// Zero disables it
+	TokenInvalidationThreshold int
+	// Clock is the time source for token expiry and retry timers, nil means the real clock
+	Clock clock.Clock
+	// Logger receives debug records of retries, token refreshes and invalidations, secrets never show up
+	// in them, nil discards them
+	Logger *slog.Logger
 }
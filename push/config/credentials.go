@@ -0,0 +1,80 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+const (
+	minSecretLength = 8
+	// HMS issues 64 character secrets, the strict bounds leave room for other formats
+	strictMinSecretLength = 32
+	strictMaxSecretLength = 128
+)
+
+// ValidateCredentials checks the format of AppId and AppSecret without any network call, to catch
+// copy-paste mistakes that would otherwise only surface as auth failures
+// Leading and trailing whitespace, e.g. the newline of a file read, is trimmed from both with a warning
+// on Logger. The app id must be numeric, and the secret at least 8 characters without whitespace or
+// control characters. StrictCredentials also requires an alphanumeric secret of 32 to 128 characters
+// The secret isn't checked with an AuthorizationProvider, which doesn't use it
+// The error lists every problem found
+func (c *Config) ValidateCredentials() error {
+	c.AppId = c.trimCredential("AppId", c.AppId)
+	c.AppSecret = c.trimCredential("AppSecret", c.AppSecret)
+
+	var problems []error
+	switch {
+	case c.AppId == "":
+		problems = append(problems, errors.New("AppId is empty"))
+	case strings.IndexFunc(c.AppId, func(r rune) bool { return r < '0' || r > '9' }) >= 0:
+		problems = append(problems, errors.New("AppId must be numeric"))
+	}
+
+	if c.AuthorizationProvider == nil {
+		problems = append(problems, c.secretProblems()...)
+	}
+	return errors.Join(problems...)
+}
+
+func (c *Config) secretProblems() []error {
+	secret := c.AppSecret
+	if secret == "" {
+		return []error{errors.New("AppSecret is empty")}
+	}
+
+	var problems []error
+	if strings.IndexFunc(secret, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		problems = append(problems, errors.New("AppSecret contains whitespace or control characters"))
+	}
+
+	if !c.StrictCredentials {
+		if len(secret) < minSecretLength {
+			problems = append(problems, fmt.Errorf("AppSecret is %d characters, expected at least %d", len(secret), minSecretLength))
+		}
+		return problems
+	}
+
+	if len(secret) < strictMinSecretLength || len(secret) > strictMaxSecretLength {
+		problems = append(problems, fmt.Errorf("AppSecret is %d characters, expected %d to %d",
+			len(secret), strictMinSecretLength, strictMaxSecretLength))
+	}
+	if strings.IndexFunc(secret, func(r rune) bool { return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) }) >= 0 {
+		problems = append(problems, errors.New("AppSecret must be alphanumeric"))
+	}
+	return problems
+}
+
+// trimCredential trims incidental whitespace, the value itself is never logged
+func (c *Config) trimCredential(name string, value string) string {
+	trimmed := strings.TrimSpace(value)
+	if trimmed != value && c.Logger != nil {
+		c.Logger.LogAttrs(context.Background(), slog.LevelWarn, "hms push: trimmed whitespace around a credential",
+			slog.String("field", name))
+	}
+	return trimmed
+}
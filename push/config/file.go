@@ -0,0 +1,220 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"time"
+)
+
+// fileConfig is the json layout of a config file, see FromFile
+type fileConfig struct {
+	AppId             string `json:"app_id"`
+	AppSecret         string `json:"app_secret"`
+	AuthUrl           string `json:"auth_url"`
+	GrantType         string `json:"grant_type"`
+	TokenScope        string `json:"token_scope"`
+	RevokeUrl         string `json:"revoke_url"`
+	PushUrl           string `json:"push_url"`
+	PathTemplate      string `json:"path_template"`
+	StatsPathTemplate string `json:"stats_path_template"`
+	ProxyUrl          string `json:"proxy_url"`
+	TrustedCA         string `json:"trusted_ca"`
+	ClientCert        string `json:"client_cert"`
+	ClientKey         string `json:"client_key"`
+
+	ForceHTTP1  bool     `json:"force_http1"`
+	DialTimeout duration `json:"dial_timeout"`
+	KeepAlive   duration `json:"keep_alive"`
+
+	MaxRetryTimes int      `json:"max_retry_times"`
+	RetryInterval duration `json:"retry_interval"`
+	DisableRetry  bool     `json:"disable_retry"`
+	Timeout       duration `json:"timeout"`
+
+	DefaultDeadline duration `json:"default_deadline"`
+
+	MaxConcurrentRequests int     `json:"max_concurrent_requests"`
+	RateLimit             float64 `json:"rate_limit"`
+	DetailedResults       bool    `json:"detailed_results"`
+	StrictValidation      bool    `json:"strict_validation"`
+	DebugAuth             bool    `json:"debug_auth"`
+
+	DataSizeWarningThreshold float64 `json:"data_size_warning_threshold"`
+
+	ExpiryMargin               duration `json:"expiry_margin"`
+	RefreshJitter              duration `json:"refresh_jitter"`
+	MinRefreshInterval         duration `json:"min_refresh_interval"`
+	WaitRefreshInterval        bool     `json:"wait_refresh_interval"`
+	ExpiryGrace                duration `json:"expiry_grace"`
+	TokenInvalidationThreshold int      `json:"token_invalidation_threshold"`
+}
+
+// duration is a time.Duration written in time.ParseDuration format, e.g. "500ms"
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"500ms\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// FromFile reads a Config from a json file, the field names are the snake case of the Config ones,
+// e.g. app_id, retry_interval, and durations are in time.ParseDuration format
+// ${NAME} in a string value is replaced by the environment variable NAME, e.g. to keep the app secret out of the file
+// DefaultMaxRetryTimes applies to a missing max_retry_times, and the config is checked for what a client needs
+// to start, every problem found is reported in the error
+func FromFile(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var fc fileConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&fc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var problems []error
+	expand := func(name string, value *string) {
+		*value = envReference.ReplaceAllStringFunc(*value, func(ref string) string {
+			variable := envReference.FindStringSubmatch(ref)[1]
+			v, ok := os.LookupEnv(variable)
+			if !ok {
+				problems = append(problems, fmt.Errorf("%s: environment variable %s is not set", name, variable))
+			}
+			return v
+		})
+	}
+	// in file order, so that the problems are reported in a stable order
+	expand("app_id", &fc.AppId)
+	expand("app_secret", &fc.AppSecret)
+	expand("auth_url", &fc.AuthUrl)
+	expand("grant_type", &fc.GrantType)
+	expand("token_scope", &fc.TokenScope)
+	expand("revoke_url", &fc.RevokeUrl)
+	expand("push_url", &fc.PushUrl)
+	expand("path_template", &fc.PathTemplate)
+	expand("stats_path_template", &fc.StatsPathTemplate)
+	expand("proxy_url", &fc.ProxyUrl)
+	expand("trusted_ca", &fc.TrustedCA)
+	expand("client_cert", &fc.ClientCert)
+	expand("client_key", &fc.ClientKey)
+
+	c := fc.config()
+	if c.MaxRetryTimes == 0 {
+		c.MaxRetryTimes = DefaultMaxRetryTimes
+	}
+	problems = append(problems, c.problems()...)
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, errors.Join(problems...))
+	}
+	return c, nil
+}
+
+func (fc *fileConfig) config() *Config {
+	return &Config{
+		AppId:                      fc.AppId,
+		AppSecret:                  fc.AppSecret,
+		AuthUrl:                    fc.AuthUrl,
+		GrantType:                  fc.GrantType,
+		TokenScope:                 fc.TokenScope,
+		RevokeUrl:                  fc.RevokeUrl,
+		PushUrl:                    fc.PushUrl,
+		PathTemplate:               fc.PathTemplate,
+		StatsPathTemplate:          fc.StatsPathTemplate,
+		ProxyUrl:                   fc.ProxyUrl,
+		TrustedCA:                  fc.TrustedCA,
+		ClientCert:                 fc.ClientCert,
+		ClientKey:                  fc.ClientKey,
+		ForceHTTP1:                 fc.ForceHTTP1,
+		DialTimeout:                time.Duration(fc.DialTimeout),
+		KeepAlive:                  time.Duration(fc.KeepAlive),
+		MaxRetryTimes:              fc.MaxRetryTimes,
+		RetryInterval:              time.Duration(fc.RetryInterval),
+		DisableRetry:               fc.DisableRetry,
+		Timeout:                    time.Duration(fc.Timeout),
+		DefaultDeadline:            time.Duration(fc.DefaultDeadline),
+		MaxConcurrentRequests:      fc.MaxConcurrentRequests,
+		RateLimit:                  fc.RateLimit,
+		DetailedResults:            fc.DetailedResults,
+		StrictValidation:           fc.StrictValidation,
+		DataSizeWarningThreshold:   fc.DataSizeWarningThreshold,
+		DebugAuth:                  fc.DebugAuth,
+		ExpiryMargin:               time.Duration(fc.ExpiryMargin),
+		RefreshJitter:              time.Duration(fc.RefreshJitter),
+		MinRefreshInterval:         time.Duration(fc.MinRefreshInterval),
+		WaitRefreshInterval:        fc.WaitRefreshInterval,
+		ExpiryGrace:                time.Duration(fc.ExpiryGrace),
+		TokenInvalidationThreshold: fc.TokenInvalidationThreshold,
+	}
+}
+
+// problems returns everything that would keep a client from starting with c
+func (c *Config) problems() []error {
+	var problems []error
+	for _, field := range c.missingFields() {
+		problems = append(problems, fmt.Errorf("%s is missing", field))
+	}
+
+	if err := ValidateRetryConfig(c.MaxRetryTimes, c.RetryInterval); err != nil {
+		problems = append(problems, err)
+	}
+
+	durations := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"dial_timeout", c.DialTimeout},
+		{"keep_alive", c.KeepAlive},
+		{"timeout", c.Timeout},
+		{"default_deadline", c.DefaultDeadline},
+		{"expiry_margin", c.ExpiryMargin},
+		{"refresh_jitter", c.RefreshJitter},
+		{"min_refresh_interval", c.MinRefreshInterval},
+		{"expiry_grace", c.ExpiryGrace},
+	}
+	for _, duration := range durations {
+		if duration.d < 0 {
+			problems = append(problems, fmt.Errorf("%s can't be negative", duration.name))
+		}
+	}
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		problems = append(problems, errors.New("client_cert and client_key must be set together"))
+	}
+	if c.MaxConcurrentRequests < 0 {
+		problems = append(problems, errors.New("max_concurrent_requests can't be negative"))
+	}
+	if c.RateLimit < 0 {
+		problems = append(problems, errors.New("rate_limit can't be negative"))
+	}
+
+	if c.AppId != "" && c.PushUrl != "" {
+		if _, err := c.SendURL(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if c.AuthUrl != "" {
+		if _, err := c.AuthURL(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrProfileNotFound is wrapped by the error Select returns for an unknown profile
+var ErrProfileNotFound = errors.New("config profile not found")
+
+// Profiles holds one Config per environment, e.g. "dev", "staging" and "prod"
+type Profiles map[string]Config
+
+// Select returns a copy of the named profile, checking it has what a client needs to start
+func (p Profiles) Select(name string) (*Config, error) {
+	c, ok := p[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrProfileNotFound, name)
+	}
+
+	if missing := c.missingFields(); len(missing) > 0 {
+		return nil, fmt.Errorf("config profile %q is incomplete, missing %s", name, strings.Join(missing, ", "))
+	}
+	return &c, nil
+}
+
+// ProfilesFromEnv reads the named profiles with FromEnv, the variables of profile "dev"
+// with prefix "HMS" start with HMS_DEV_
+func ProfilesFromEnv(prefix string, names ...string) (Profiles, error) {
+	profiles := make(Profiles, len(names))
+	for _, name := range names {
+		c, err := FromEnv(prefix + "_" + strings.ToUpper(name))
+		if err != nil {
+			return nil, fmt.Errorf("config profile %q: %w", name, err)
+		}
+		profiles[name] = *c
+	}
+	return profiles, nil
+}
+
+// FromEnv reads a Config from the environment variables named prefix followed by
+// _APP_ID, _APP_SECRET, _AUTH_URL, _PUSH_URL, _PROXY_URL, _TRUSTED_CA, _CLIENT_CERT, _CLIENT_KEY,
+// _MAX_RETRY_TIMES, _RETRY_INTERVAL and _TIMEOUT, durations are in time.ParseDuration format
+// Unset variables leave their field empty
+func FromEnv(prefix string) (*Config, error) {
+	env := func(name string) string {
+		return os.Getenv(prefix + "_" + name)
+	}
+
+	c := &Config{
+		AppId:      env("APP_ID"),
+		AppSecret:  env("APP_SECRET"),
+		AuthUrl:    env("AUTH_URL"),
+		PushUrl:    env("PUSH_URL"),
+		ProxyUrl:   env("PROXY_URL"),
+		TrustedCA:  env("TRUSTED_CA"),
+		ClientCert: env("CLIENT_CERT"),
+		ClientKey:  env("CLIENT_KEY"),
+	}
+
+	if value := env("MAX_RETRY_TIMES"); value != "" {
+		maxRetryTimes, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s_MAX_RETRY_TIMES: %w", prefix, err)
+		}
+		c.MaxRetryTimes = maxRetryTimes
+	}
+
+	durations := map[string]*time.Duration{
+		"RETRY_INTERVAL": &c.RetryInterval,
+		"TIMEOUT":        &c.Timeout,
+	}
+	for name, field := range durations {
+		if value := env(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s_%s: %w", prefix, name, err)
+			}
+			*field = d
+		}
+	}
+	return c, nil
+}
+
+// missingFields returns the names of the fields a client can't start without
+func (c *Config) missingFields() []string {
+	var missing []string
+	if c.AppId == "" {
+		missing = append(missing, "AppId")
+	}
+	if c.PushUrl == "" {
+		missing = append(missing, "PushUrl")
+	}
+
+	// the credentials aren't used when the authorization comes from a provider
+	if c.AuthorizationProvider == nil {
+		if c.AppSecret == "" {
+			missing = append(missing, "AppSecret")
+		}
+		if c.AuthUrl == "" {
+			missing = append(missing, "AuthUrl")
+		}
+	}
+	return missing
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultMaxRetryTimes is the number of attempts a zero MaxRetryTimes stands for
+	DefaultMaxRetryTimes = 1
+	// MaxRetryTimesLimit is the largest MaxRetryTimes accepted
+	MaxRetryTimesLimit = 5
+)
+
+// ValidateRetryConfig checks retry settings, config and httpclient both use it so they agree on the bounds
+// A zero maxRetryTimes is valid, it stands for DefaultMaxRetryTimes
+func ValidateRetryConfig(maxRetryTimes int, retryInterval time.Duration) error {
+	if maxRetryTimes < 0 || maxRetryTimes > MaxRetryTimesLimit {
+		return fmt.Errorf("maximum retry times can't be negative or more than %d, 0 means %d", MaxRetryTimesLimit, DefaultMaxRetryTimes)
+	}
+
+	if retryInterval < 0 {
+		return errors.New("retry interval can't be negative")
+	}
+	return nil
+}
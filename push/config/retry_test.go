@@ -0,0 +1,30 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/push/config"
+)
+
+func TestValidateRetryConfig(t *testing.T) {
+	tests := []struct {
+		maxRetryTimes int
+		retryInterval time.Duration
+		wantErr       bool
+	}{
+		{0, 0, false},
+		{1, time.Second, false},
+		{config.MaxRetryTimesLimit, 0, false},
+		{-1, 0, true},
+		{config.MaxRetryTimesLimit + 1, 0, true},
+		{1, -time.Second, true},
+	}
+
+	for _, tt := range tests {
+		err := config.ValidateRetryConfig(tt.maxRetryTimes, tt.retryInterval)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateRetryConfig(%d, %s) = %v, want error %t", tt.maxRetryTimes, tt.retryInterval, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+)
+
+// SendURL returns the send message url, PushUrl joined with PathTemplate or the default send path
+func (c *Config) SendURL() (string, error) {
+	path := c.PathTemplate
+	if path == "" {
+		path = constant.DefaultSendPathTemplate
+	}
+	return c.pushURL(path)
+}
+
+// SubscribeTopicURL returns the topic subscription url
+func (c *Config) SubscribeTopicURL() (string, error) {
+	return c.pushURL(constant.SubscribeTopicPathTemplate)
+}
+
+// UnsubscribeTopicURL returns the topic unsubscription url
+func (c *Config) UnsubscribeTopicURL() (string, error) {
+	return c.pushURL(constant.UnsubscribeTopicPathTemplate)
+}
+
+// ListTopicsURL returns the url listing the topics of a token
+func (c *Config) ListTopicsURL() (string, error) {
+	return c.pushURL(constant.ListTopicsPathTemplate)
+}
+
+// StatsURL returns the message statistics url, empty without StatsPathTemplate
+func (c *Config) StatsURL() (string, error) {
+	if c.StatsPathTemplate == "" {
+		return "", nil
+	}
+	return c.pushURL(c.StatsPathTemplate)
+}
+
+// AuthURL returns AuthUrl once checked to be an absolute url
+func (c *Config) AuthURL() (string, error) {
+	if _, err := parseBaseURL("authUrl", c.AuthUrl); err != nil {
+		return "", err
+	}
+	return c.AuthUrl, nil
+}
+
+// pushURL joins PushUrl and the path template, whatever trailing slash PushUrl has
+func (c *Config) pushURL(pathTemplate string) (string, error) {
+	if c.AppId == "" {
+		return "", errors.New("appId can't be empty")
+	}
+
+	if !strings.Contains(pathTemplate, constant.AppIdPlaceholder) {
+		return "", fmt.Errorf("path template %q must contain the %s placeholder", pathTemplate, constant.AppIdPlaceholder)
+	}
+
+	u, err := parseBaseURL("pushUrl", c.PushUrl)
+	if err != nil {
+		return "", err
+	}
+
+	path := strings.ReplaceAll(pathTemplate, constant.AppIdPlaceholder, c.AppId)
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + strings.TrimLeft(path, "/")
+	return u.String(), nil
+}
+
+func parseBaseURL(name string, value string) (*url.URL, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%s can't be empty", name)
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%s must be an absolute url", name)
+	}
+	return u, nil
+}
@@ -0,0 +1,58 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/push/config"
+)
+
+func TestSendURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		pushUrl      string
+		pathTemplate string
+		want         string
+		wantErr      bool
+	}{
+		{"no trailing slash", "https://push-api.cloud.huawei.com", "", "https://push-api.cloud.huawei.com/v1/app/messages:send", false},
+		{"trailing slash", "https://push-api.cloud.huawei.com/", "", "https://push-api.cloud.huawei.com/v1/app/messages:send", false},
+		{"path prefix", "https://gateway.example.com/hms", "", "https://gateway.example.com/hms/v1/app/messages:send", false},
+		{"path prefix with trailing slash", "https://gateway.example.com/hms/", "", "https://gateway.example.com/hms/v1/app/messages:send", false},
+		{"path template", "https://gateway.example.com/", "v2/{appId}/send", "https://gateway.example.com/v2/app/send", false},
+		{"path template without app id", "https://gateway.example.com", "/v2/send", "", true},
+		{"empty", "", "", "", true},
+		{"relative", "push-api.cloud.huawei.com/v1", "", "", true},
+		{"invalid", "https://push api.cloud.huawei.com", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &config.Config{AppId: "app", PushUrl: tt.pushUrl, PathTemplate: tt.pathTemplate}
+			got, err := conf.SendURL()
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("SendURL() = %q, %v, want %q, error %t", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthURL(t *testing.T) {
+	tests := []struct {
+		authUrl string
+		wantErr bool
+	}{
+		{"https://oauth-login.cloud.huawei.com/oauth2/v3/token", false},
+		{"https://oauth-login.cloud.huawei.com/oauth2/v3/token/", false},
+		{"", true},
+		{"/oauth2/v3/token", true},
+		{"https://oauth login.cloud.huawei.com", true},
+	}
+
+	for _, tt := range tests {
+		conf := &config.Config{AuthUrl: tt.authUrl}
+		got, err := conf.AuthURL()
+		if (err != nil) != tt.wantErr || (err == nil && got != tt.authUrl) {
+			t.Errorf("AuthURL() of %q = %q, %v, want error %t", tt.authUrl, got, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package constant
+
+// result codes returned by the push server
+const (
+	CodeSuccess                = "80000000"
+	CodePartialSuccess         = "80100000"
+	CodeInvalidParameter       = "80100001"
+	CodeInvalidMessage         = "80100003"
+	CodeMessageExpired         = "80100004"
+	CodeInvalidCollapseKey     = "80100013"
+	CodeSensitiveContent       = "80100016"
+	CodeQuotaExceeded          = "80100017"
+	CodeInvalidMessageBody     = "80100018"
+	CodeTokenFailed            = "80200001"
+	CodeTokenExpired           = "80200003"
+	CodePermissionDenied       = "80300002"
+	CodeAllTokensInvalid       = "80300007"
+	CodeMessageTooLarge        = "80300008"
+	CodeTooManyTokens          = "80300010"
+	CodeHighPriorityNotAllowed = "80300011"
+	CodeAuthServiceFailed      = "80600003"
+	CodeInternalError          = "81000001"
+)
+
+var codeDescriptions = map[string]string{
+	CodeSuccess:                "success",
+	CodePartialSuccess:         "some tokens failed, see illegal_tokens",
+	CodeInvalidParameter:       "some request parameters are incorrect",
+	CodeInvalidMessage:         "incorrect message structure",
+	CodeMessageExpired:         "the message expiration time is earlier than the current time",
+	CodeInvalidCollapseKey:     "invalid collapse_key",
+	CodeSensitiveContent:       "the message contains sensitive information",
+	CodeQuotaExceeded:          "too many messages sent, flow control applied",
+	CodeInvalidMessageBody:     "invalid message body",
+	CodeTokenFailed:            "OAuth authentication error",
+	CodeTokenExpired:           "OAuth token expired",
+	CodePermissionDenied:       "the app is not allowed to send messages",
+	CodeAllTokensInvalid:       "all tokens are invalid",
+	CodeMessageTooLarge:        "the message body size exceeds the limit",
+	CodeTooManyTokens:          "the number of tokens exceeds the limit",
+	CodeHighPriorityNotAllowed: "the app is not allowed to send high-priority messages",
+	CodeAuthServiceFailed:      "failed to request the OAuth service",
+	CodeInternalError:          "push server internal error",
+}
+
+// CodeDescription returns a human-readable description of a result code
+func CodeDescription(code string) string {
+	if description, ok := codeDescriptions[code]; ok {
+		return description
+	}
+	return "unknown result code " + code
+}
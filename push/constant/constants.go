@@ -34,6 +34,40 @@ const (
 	DeliveryPriorityNormal = "NORMAL"
 )
 
+// Category is the scenario of an android message, see model.AndroidConfig.WithCategory
+type Category string
+
+const (
+	// instant message
+	CategoryIM Category = "IM"
+	// voice or video call
+	CategoryVoIP Category = "VOIP"
+	// subscription
+	CategorySubscription Category = "SUBSCRIPTION"
+	// travel
+	CategoryTravel Category = "TRAVEL"
+	// health
+	CategoryHealth Category = "HEALTH"
+	// work
+	CategoryWork Category = "WORK"
+	// account
+	CategoryAccount Category = "ACCOUNT"
+	// express delivery
+	CategoryExpress Category = "EXPRESS"
+	// finance
+	CategoryFinance Category = "FINANCE"
+	// device reminder
+	CategoryDeviceReminder Category = "DEVICE_REMINDER"
+	// system reminder
+	CategorySystemReminder Category = "SYSTEM_REMINDER"
+	// mail
+	CategoryMail Category = "MAIL"
+	// voice broadcast
+	CategoryPlayVoice Category = "PLAY_VOICE"
+	// marketing, it can't be combined with the high delivery priority
+	CategoryMarketing Category = "MARKETING"
+)
+
 const (
 	// high priority
 	NotificationPriorityHigh = "HIGH"
@@ -43,6 +77,31 @@ const (
 	NotificationPriorityLow = "LOW"
 )
 
+const (
+	// apns-priority header sending the notification immediately
+	ApnsPriorityImmediate = "10"
+	// apns-priority header sending the notification considering the device power
+	ApnsPriorityPowerConsiderate = "5"
+)
+
+// apns-push-type header values
+const (
+	ApnsPushTypeAlert        = "alert"
+	ApnsPushTypeBackground   = "background"
+	ApnsPushTypeVoip         = "voip"
+	ApnsPushTypeComplication = "complication"
+	ApnsPushTypeFileProvider = "fileprovider"
+	ApnsPushTypeMdm          = "mdm"
+	ApnsPushTypeLocation     = "location"
+	ApnsPushTypeLiveActivity = "liveactivity"
+)
+
+const (
+	// ApnsMaxPayloadSize is the largest apns payload in bytes, ApnsMaxVoipPayloadSize the largest voip one
+	ApnsMaxPayloadSize     = 4096
+	ApnsMaxVoipPayloadSize = 5120
+)
+
 const (
 	// very low urgency
 	UrgencyVeryLow = "very-low"
@@ -65,13 +124,13 @@ const (
 
 const (
 	// success code from push server
-	Success = "80000000"
+	Success = CodeSuccess
 	// parameter invalid code from push server
-	ParameterError = "80100001"
+	ParameterError = CodeInvalidParameter
 	// token invalid code from push server
-	TokenFailedErr = "80200001"
+	TokenFailedErr = CodeTokenFailed
 	//token timeout code from push server
-	TokenTimeoutErr = "80200003"
+	TokenTimeoutErr = CodeTokenExpired
 )
 
 const (
@@ -98,3 +157,33 @@ const (
 	// VoIP user
 	TargetUserTypeVoIP
 )
+
+const (
+	// message delivered to an android or iOS app
+	PlatformApp = "app"
+	// message delivered to a web app through web push
+	PlatformWeb = "web"
+	// message delivered to a quick app
+	PlatformFastApp = "fastapp"
+)
+
+const (
+	// AnalyticInfoMaxKeyLength and AnalyticInfoMaxValueLength bound the characters of the analytic info entries
+	AnalyticInfoMaxKeyLength   = 64
+	AnalyticInfoMaxValueLength = 256
+)
+
+// MaxDataSize is the largest data payload of a message in bytes, for data and android.data alike
+const MaxDataSize = 4096
+
+// MaxTokensPerMessage is the maximum number of tokens of one message
+const MaxTokensPerMessage = 1000
+
+const (
+	// message sent to a list of tokens
+	TargetKindToken = "token"
+	// message sent to a topic
+	TargetKindTopic = "topic"
+	// message sent to a condition over topics
+	TargetKindCondition = "condition"
+)
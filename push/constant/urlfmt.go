@@ -18,5 +18,19 @@ package constant
 
 const (
 	//the parameters of the formats below are endpoint and appId
-	SendMessageFmt = "%s/v1/%s/messages:send"
+	SendMessageFmt      = "%s/v1/%s/messages:send"
+	SubscribeTopicFmt   = "%s/v1/%s/topic:subscribe"
+	UnsubscribeTopicFmt = "%s/v1/%s/topic:unsubscribe"
+	ListTopicsFmt       = "%s/v1/%s/topic:list"
+)
+
+const (
+	// AppIdPlaceholder is replaced by the app id in path templates
+	AppIdPlaceholder = "{appId}"
+	// DefaultSendPathTemplate is the path template matching SendMessageFmt
+	DefaultSendPathTemplate = "/v1/" + AppIdPlaceholder + "/messages:send"
+	// the path templates matching the topic formats
+	SubscribeTopicPathTemplate   = "/v1/" + AppIdPlaceholder + "/topic:subscribe"
+	UnsubscribeTopicPathTemplate = "/v1/" + AppIdPlaceholder + "/topic:unsubscribe"
+	ListTopicsPathTemplate       = "/v1/" + AppIdPlaceholder + "/topic:list"
 )
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+var (
+	// ErrQueueFull is returned by EnqueueSend when the queue has no room left
+	ErrQueueFull = errors.New("async send queue is full")
+	// ErrSenderClosed is returned by EnqueueSend after Close
+	ErrSenderClosed = errors.New("async sender is closed")
+)
+
+// AsyncResult is the outcome of a message queued on an AsyncSender, sent with HMSClient.Send
+// A result code other than success or partial success is in Err as *PushError, along with Result
+type AsyncResult struct {
+	// Result is nil when the send failed without a response
+	Result *model.SendResult
+	// Response holds the code, msg and request id of Result
+	Response *model.MessageResponse
+	Err      error
+}
+
+type asyncJob struct {
+	ctx        context.Context
+	msgRequest *model.MessageRequest
+	result     chan *AsyncResult
+}
+
+// AsyncSender sends queued messages through a bounded pool of workers
+type AsyncSender struct {
+	client *HMSClient
+	jobs   chan *asyncJob
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncSender starts workers goroutines serving a queue of up to queueSize messages
+// The sender must be closed with Close to release the workers
+func (c *HMSClient) NewAsyncSender(workers int, queueSize int) (*AsyncSender, error) {
+	if workers < 1 {
+		return nil, errors.New("workers can't be less than 1")
+	}
+
+	if queueSize < 0 {
+		return nil, errors.New("queueSize can't be negative")
+	}
+
+	s := &AsyncSender{
+		client: c,
+		jobs:   make(chan *asyncJob, queueSize),
+	}
+
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s, nil
+}
+
+// EnqueueSend queues a message and returns a channel which receives exactly one result
+// It doesn't block: ErrQueueFull is returned when the queue is full
+func (s *AsyncSender) EnqueueSend(ctx context.Context, msgRequest *model.MessageRequest) (<-chan *AsyncResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrSenderClosed
+	}
+
+	job := &asyncJob{
+		ctx:        ctx,
+		msgRequest: msgRequest,
+		result:     make(chan *AsyncResult, 1),
+	}
+
+	select {
+	case s.jobs <- job:
+		return job.result, nil
+	default:
+		return nil, ErrQueueFull
+	}
+}
+
+// Close stops accepting messages and waits until the queued ones are sent
+func (s *AsyncSender) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.jobs)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *AsyncSender) work() {
+	defer s.wg.Done()
+
+	for job := range s.jobs {
+		// Send, so that the interceptors of the client apply to the queued messages too
+		result, err := s.client.Send(job.ctx, job.msgRequest)
+		asyncResult := &AsyncResult{Result: result, Err: err}
+		if result != nil {
+			asyncResult.Response = &model.MessageResponse{Code: result.Code, Msg: result.Msg, RequestId: result.RequestId}
+		}
+		job.result <- asyncResult
+	}
+}
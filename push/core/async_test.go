@@ -0,0 +1,62 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/core"
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+func TestAsyncSenderSendsThroughSend(t *testing.T) {
+	client, server := newTestClient(t)
+
+	var intercepted atomic.Int32
+	client.Use(func(next core.SendFunc) core.SendFunc {
+		return func(ctx context.Context, msgRequest *model.MessageRequest) (*model.SendResult, error) {
+			intercepted.Add(1)
+			return next(ctx, msgRequest)
+		}
+	})
+
+	sender, err := client.NewAsyncSender(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.RespondError(http.StatusOK, constant.CodeInvalidMessage, "invalid message")
+
+	// a single worker sends the queued messages in order
+	failed, err := sender.EnqueueSend(context.Background(), tokenMessage("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	succeeded, err := sender.EnqueueSend(context.Background(), tokenMessage("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.Close()
+
+	result := <-failed
+	var pushErr *core.PushError
+	if !errors.As(result.Err, &pushErr) || pushErr.Code != constant.CodeInvalidMessage {
+		t.Errorf("error = %v, want a *PushError with code %s", result.Err, constant.CodeInvalidMessage)
+	}
+	if result.Result == nil || result.Result.Code != constant.CodeInvalidMessage ||
+		result.Response == nil || result.Response.Code != constant.CodeInvalidMessage {
+		t.Errorf("result = %+v, response = %+v, want both with the failure code", result.Result, result.Response)
+	}
+
+	result = <-succeeded
+	if result.Err != nil || result.Result == nil || result.Result.Code != constant.CodeSuccess ||
+		result.Response.RequestId != result.Result.RequestId {
+		t.Errorf("result = %+v, response = %+v, %v, want a success", result.Result, result.Response, result.Err)
+	}
+
+	if intercepted.Load() != 2 {
+		t.Errorf("interceptor calls = %d, want one per queued message", intercepted.Load())
+	}
+}
@@ -0,0 +1,224 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+type batchOptions struct {
+	chunkSize        int
+	deduplicate      bool
+	maxIllegalTokens int
+	onIllegalTokens  func(tokens []string)
+	concurrency      int
+}
+
+// BatchOption customizes SendToTokens
+type BatchOption func(o *batchOptions)
+
+// ChunkSize sets the number of tokens per request, at most constant.MaxTokensPerMessage which is the default
+func ChunkSize(size int) BatchOption {
+	return func(o *batchOptions) {
+		o.chunkSize = size
+	}
+}
+
+// DeduplicateTokens sets whether duplicate tokens are dropped before chunking, keeping the first one. It's on by default
+func DeduplicateTokens(enabled bool) BatchOption {
+	return func(o *batchOptions) {
+		o.deduplicate = enabled
+	}
+}
+
+// MaxIllegalTokens caps the illegal tokens kept in the result, the others are only counted,
+// see model.NewBatchResult. Zero, the default, keeps them all
+func MaxIllegalTokens(max int) BatchOption {
+	return func(o *batchOptions) {
+		o.maxIllegalTokens = max
+	}
+}
+
+// OnIllegalTokens streams the illegal tokens of each chunk to f as the chunks complete,
+// e.g. to delete them from storage whatever MaxIllegalTokens keeps
+func OnIllegalTokens(f func(tokens []string)) BatchOption {
+	return func(o *batchOptions) {
+		o.onIllegalTokens = f
+	}
+}
+
+// Concurrency sets the number of chunks SendFromReader sends at once, 1 by default
+// The rate limit of the client still applies to all of them
+func Concurrency(n int) BatchOption {
+	return func(o *batchOptions) {
+		o.concurrency = n
+	}
+}
+
+func newBatchOptions(opts []BatchOption) (*batchOptions, error) {
+	o := &batchOptions{
+		chunkSize:   constant.MaxTokensPerMessage,
+		deduplicate: true,
+		concurrency: 1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.chunkSize < 1 || o.chunkSize > constant.MaxTokensPerMessage {
+		return nil, fmt.Errorf("chunk size must be in interval [1 - %d]", constant.MaxTokensPerMessage)
+	}
+
+	if o.maxIllegalTokens < 0 {
+		return nil, errors.New("maximum illegal tokens can't be negative")
+	}
+
+	if o.concurrency < 1 {
+		return nil, errors.New("concurrency can't be less than 1")
+	}
+	return o, nil
+}
+
+// PlanSend returns how many requests SendToTokens would make for tokens with the same options,
+// and how long the configured rate limit would take to let them through, without sending anything
+func (c *HMSClient) PlanSend(tokens []string, opts ...BatchOption) (*model.SendPlan, error) {
+	o, err := newBatchOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.deduplicate {
+		tokens = dedupTokens(tokens)
+	}
+
+	plan := &model.SendPlan{
+		Tokens:            len(tokens),
+		ChunkSize:         o.chunkSize,
+		Chunks:            (len(tokens) + o.chunkSize - 1) / o.chunkSize,
+		RequestsPerSecond: c.client.RateLimit(),
+	}
+	if plan.RequestsPerSecond > 0 && plan.Chunks > 1 {
+		// the first request goes out right away
+		plan.EstimatedDuration = time.Duration(float64(plan.Chunks-1) / plan.RequestsPerSecond * float64(time.Second))
+	}
+	return plan, nil
+}
+
+// SendToTokens sends the message of msgRequest to every token, in chunks of at most ChunkSize tokens
+// msgRequest is a template: each chunk is sent with a clone having its tokens as target
+// The returned error reports failed chunks, their details are in the result
+// Once ctx is done no further chunk is sent, the remaining tokens are in the result Unsent for rescheduling
+// and the error wraps the context error; a chunk in flight at that point is cancelled and fails
+func (c *HMSClient) SendToTokens(ctx context.Context, msgRequest *model.MessageRequest, tokens []string, opts ...BatchOption) (*model.BatchResult, error) {
+	o, err := newBatchOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, errors.New("token must not be empty")
+	}
+
+	result := model.NewBatchResult(o.maxIllegalTokens, o.onIllegalTokens)
+	if o.deduplicate {
+		unique := dedupTokens(tokens)
+		result.DuplicatesRemoved = len(tokens) - len(unique)
+		tokens = unique
+	}
+
+	var firstErr error
+	failed := 0
+	for start := 0; start < len(tokens); start += o.chunkSize {
+		if err = ctx.Err(); err != nil {
+			result.Unsent = append([]string(nil), tokens[start:]...)
+			return result, fmt.Errorf("batch stopped with %d of %d tokens unsent: %w", len(result.Unsent), len(tokens), err)
+		}
+
+		end := start + o.chunkSize
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+
+		chunk := c.sendChunk(ctx, msgRequest, tokens[start:end])
+		result.Add(chunk)
+		if chunk.Err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = chunk.Err
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return result, chunksFailed(failed, len(result.Chunks), firstErr)
+	}
+	return result, nil
+}
+
+// SendToToken sends the message of msgRequest to a single token, with the same result and error as
+// SendToTokens but without its de-duplication, chunking and deep copy of the message
+func (c *HMSClient) SendToToken(ctx context.Context, msgRequest *model.MessageRequest, token string) (*model.BatchResult, error) {
+	if token == "" {
+		return nil, errors.New("token must not be empty")
+	}
+
+	if msgRequest == nil {
+		return nil, errors.New("message request must not be null")
+	}
+
+	// a shallow copy is enough, the send clones the message before changing it
+	request := &model.MessageRequest{ValidateOnly: msgRequest.ValidateOnly}
+	message := model.Message{}
+	if msgRequest.Message != nil {
+		message = *msgRequest.Message
+	}
+	tokens := []string{token}
+	message.Token = tokens
+	message.Topic = ""
+	message.Condition = ""
+	request.Message = &message
+
+	sendResult, err := c.Send(ctx, request)
+	chunk := &model.ChunkResult{Tokens: tokens, Result: sendResult, Err: err}
+	result := &model.BatchResult{}
+	result.Add(chunk)
+	if chunk.Err != nil {
+		return result, chunksFailed(1, 1, chunk.Err)
+	}
+	return result, nil
+}
+
+func chunksFailed(failed int, total int, firstErr error) error {
+	return fmt.Errorf("%d of %d chunks failed, first error: %w", failed, total, firstErr)
+}
+
+func (c *HMSClient) sendChunk(ctx context.Context, msgRequest *model.MessageRequest, tokens []string) *model.ChunkResult {
+	request := msgRequest.Clone()
+	if request.Message == nil {
+		request.Message = &model.Message{}
+	}
+	request.Message.Token = tokens
+	request.Message.Topic = ""
+	request.Message.Condition = ""
+
+	sendResult, err := c.Send(ctx, request)
+	return &model.ChunkResult{Tokens: tokens, Result: sendResult, Err: err}
+}
+
+// dedupTokens returns tokens without duplicates, in first seen order
+func dedupTokens(tokens []string) []string {
+	seen := make(map[string]struct{}, len(tokens))
+	unique := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		unique = append(unique, token)
+	}
+	return unique
+}
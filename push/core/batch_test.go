@@ -0,0 +1,53 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/push/core"
+	"github.com/dafanasiev/go-hms-push/push/model"
+	"github.com/dafanasiev/go-hms-push/push/pushtest"
+)
+
+func newBenchmarkClient(b *testing.B) (*core.HMSClient, *model.MessageRequest) {
+	b.Helper()
+
+	server := pushtest.NewServer()
+	b.Cleanup(server.Close)
+
+	client, err := core.NewHttpClient(server.Config())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	msgRequest := model.NewNotificationMsgRequest()
+	msgRequest.Message.Notification = &model.Notification{Title: "title", Body: "body"}
+	return client, msgRequest
+}
+
+func BenchmarkSendToToken(b *testing.B) {
+	client, msgRequest := newBenchmarkClient(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.SendToToken(ctx, msgRequest, "token"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSendToTokens(b *testing.B) {
+	client, msgRequest := newBenchmarkClient(b)
+	ctx := context.Background()
+	tokens := []string{"token"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.SendToTokens(ctx, msgRequest, tokens); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
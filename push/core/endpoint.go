@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dafanasiev/go-hms-push/httpclient"
+	"github.com/dafanasiev/go-hms-push/push/config"
+)
+
+// ErrUnknownEndpoint is wrapped by the error of a request routed with UseEndpoint to a name
+// the client wasn't given with WithAlternateEndpoint
+var ErrUnknownEndpoint = errors.New("unknown push endpoint")
+
+type endpointKey struct{}
+
+// UseEndpoint routes the requests of ctx to the alternate endpoint registered under name with
+// WithAlternateEndpoint, e.g. to send a fraction of the traffic to a new region
+// The results of the sends name the endpoint in SendResult.Endpoint
+func UseEndpoint(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, endpointKey{}, name)
+}
+
+func endpointFrom(ctx context.Context) string {
+	name, _ := ctx.Value(endpointKey{}).(string)
+	return name
+}
+
+// WithAlternateEndpoint registers pushUrl under name, the requests of a context from UseEndpoint go there
+// instead of config.Config.PushUrl, with the same app id, path templates and access token
+// A nil transport shares the http client, its connections and its limits, with the main endpoint
+// Otherwise the endpoint gets an http client of its own: a connection pool, and a concurrency and rate limit
+// of the configured size on top of those of the main endpoint, so each of them costs idle connections
+// and their TLS sessions
+func WithAlternateEndpoint(name string, pushUrl string, transport *httpclient.HTTPTransportConfig) ClientOption {
+	return func(c *HMSClient) {
+		c.pendingEndpoints = append(c.pendingEndpoints, &endpointSpec{name: name, pushUrl: pushUrl, transport: transport})
+	}
+}
+
+type endpointSpec struct {
+	name      string
+	pushUrl   string
+	transport *httpclient.HTTPTransportConfig
+}
+
+type endpoint struct {
+	urls   *clientURLs
+	client *httpclient.HTTPClient
+}
+
+// buildEndpoints builds the endpoints of WithAlternateEndpoint, after the options so that they
+// pick up the retry policy
+func (c *HMSClient) buildEndpoints(conf *config.Config, httpClientCfg *httpclient.HTTPClientConfig) error {
+	for _, spec := range c.pendingEndpoints {
+		if spec.name == "" {
+			return errors.New("alternate endpoint name can't be empty")
+		}
+		if _, ok := c.endpoints[spec.name]; ok {
+			return fmt.Errorf("alternate endpoint %q is registered twice", spec.name)
+		}
+
+		alternate := *conf
+		alternate.PushUrl = spec.pushUrl
+		urls, err := newClientURLs(&alternate)
+		if err != nil {
+			return fmt.Errorf("alternate endpoint %q: %w", spec.name, err)
+		}
+
+		client := c.client
+		if spec.transport != nil {
+			cfg := *httpClientCfg
+			cfg.TransportConfig = spec.transport
+			if client, err = httpclient.NewHTTPClient(&cfg); err != nil {
+				return fmt.Errorf("alternate endpoint %q: failed to get http client: %w", spec.name, err)
+			}
+			client.RetryConfig.RetryPolicy = c.client.RetryConfig.RetryPolicy
+		}
+
+		if c.endpoints == nil {
+			c.endpoints = map[string]*endpoint{}
+		}
+		c.endpoints[spec.name] = &endpoint{urls: urls, client: client}
+	}
+	c.pendingEndpoints = nil
+	return nil
+}
+
+// route returns the urls and the http client of the endpoint ctx is routed to
+func (c *HMSClient) route(ctx context.Context) (*clientURLs, *httpclient.HTTPClient, error) {
+	name := endpointFrom(ctx)
+	if name == "" {
+		return c.urls, c.client, nil
+	}
+
+	e, ok := c.endpoints[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnknownEndpoint, name)
+	}
+	return e.urls, e.client, nil
+}
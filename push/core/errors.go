@@ -0,0 +1,109 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/httpclient"
+	"github.com/dafanasiev/go-hms-push/push/constant"
+)
+
+// ErrUnauthorized is returned when the push server answers 401 to a request sent again with a refreshed access token
+// A 401 from the auth endpoint is an *auth.AuthError instead, since refreshing can't fix bad credentials
+var ErrUnauthorized = errors.New("the push server rejected the access token")
+
+// ErrStatsNotConfigured is returned by QueryMessageStatus when config.Config.StatsPathTemplate is empty
+var ErrStatsNotConfigured = errors.New("message statistics path is not configured")
+
+// ErrSuppressed is returned for a send the ShouldSend hook of WithShouldSend turned down,
+// nothing was sent to HMS
+var ErrSuppressed = errors.New("the send was suppressed")
+
+// StatsUnavailableError reports that the account can't query message statistics,
+// the server answered 403 or 404 to the statistics endpoint
+type StatsUnavailableError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatsUnavailableError) Error() string {
+	return fmt.Sprintf("message statistics are not available for this app: status %d", e.StatusCode)
+}
+
+// maxParseErrorBody caps the body kept by ResponseParseError
+const maxParseErrorBody = 1024
+
+// ResponseParseError reports a response body that isn't the expected json, e.g. an html error page of a proxy
+type ResponseParseError struct {
+	StatusCode int
+	// Body is the start of the raw body, at most 1KB
+	Body []byte
+	Err  error
+}
+
+func newResponseParseError(resp *httpclient.PushResponse, err error) *ResponseParseError {
+	body := resp.Body
+	if len(body) > maxParseErrorBody {
+		body = body[:maxParseErrorBody]
+	}
+	return &ResponseParseError{StatusCode: resp.Status, Body: append([]byte(nil), body...), Err: err}
+}
+
+func (e *ResponseParseError) Error() string {
+	return fmt.Sprintf("failed to parse response: status %d: %s: %q", e.StatusCode, e.Err, e.Body)
+}
+
+func (e *ResponseParseError) Unwrap() error {
+	return e.Err
+}
+
+// PushError reports a send the push server answered with a result code other than success or partial success
+// The server may do so with any http status, 200 included
+type PushError struct {
+	StatusCode int
+	Code       string
+	Msg        string
+	RequestId  string
+}
+
+func (e *PushError) Error() string {
+	return fmt.Sprintf("push failed: status %d, code %s (%s): %s", e.StatusCode, e.Code, constant.CodeDescription(e.Code), e.Msg)
+}
+
+// QuotaError reports that the push server throttled the request
+// Retrying right away deepens the throttling, callers should wait for RetryAfter at least
+type QuotaError struct {
+	StatusCode int
+	Code       string
+	Msg        string
+	// RetryAfter is the delay the server asked to wait before retrying, zero if it gave none
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	msg := fmt.Sprintf("push quota exceeded: status %d, code %s", e.StatusCode, e.Code)
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(", retry after %s", e.RetryAfter)
+	}
+	return msg
+}
+
+func isQuotaExceeded(status int, code string) bool {
+	return status == http.StatusTooManyRequests || code == constant.CodeQuotaExceeded
+}
+
+func (c *HMSClient) newQuotaError(resp *httpclient.PushResponse, code string, msg string) *QuotaError {
+	quotaErr := &QuotaError{
+		StatusCode: resp.Status,
+		Code:       code,
+		Msg:        msg,
+	}
+	quotaErr.RetryAfter, _ = resp.RetryAfterAt(c.clock.Now())
+	return quotaErr
+}
+
+func isSendSuccess(code string) bool {
+	return code == constant.CodeSuccess || code == constant.CodePartialSuccess
+}
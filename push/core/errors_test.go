@@ -0,0 +1,74 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/push/config"
+	"github.com/dafanasiev/go-hms-push/push/core"
+	"github.com/dafanasiev/go-hms-push/push/pushtest"
+)
+
+// rawSendServer issues tokens like pushtest.Server but answers every send with status and body as is
+func rawSendServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == pushtest.AuthPath {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSendNonJSONBodyIsResponseParseError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"ok", http.StatusOK, "<html>maintenance</html>", "<html>maintenance</html>"},
+		{"bad gateway", http.StatusBadGateway, "<html>bad gateway</html>", "<html>bad gateway</html>"},
+		{"long body", http.StatusOK, strings.Repeat("x", 4096), strings.Repeat("x", 1024)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := rawSendServer(t, tt.status, tt.body)
+			client, err := core.NewHttpClient(&config.Config{
+				AppId:         pushtest.AppId,
+				AppSecret:     pushtest.AppSecret,
+				AuthUrl:       server.URL + pushtest.AuthPath,
+				PushUrl:       server.URL,
+				MaxRetryTimes: 1,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.Send(context.Background(), tokenMessage("token"))
+			var parseErr *core.ResponseParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("error = %v, want *ResponseParseError", err)
+			}
+			if parseErr.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", parseErr.StatusCode, tt.status)
+			}
+			if !bytes.Equal(parseErr.Body, []byte(tt.want)) {
+				t.Errorf("body = %q, want %q", parseErr.Body, tt.want)
+			}
+		})
+	}
+}
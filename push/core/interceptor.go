@@ -0,0 +1,27 @@
+package core
+
+import (
+	"context"
+
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+// SendFunc sends a message request, it's what interceptors wrap
+type SendFunc func(ctx context.Context, msgRequest *model.MessageRequest) (*model.SendResult, error)
+
+// Interceptor wraps a SendFunc with cross-cutting behavior like logging, metrics or enrichment
+// It sees the request before calling next and the result after, and may skip next altogether
+type Interceptor func(next SendFunc) SendFunc
+
+// Use adds interceptors around every Send, the first one added runs outermost
+// It isn't safe to call concurrently with sends
+func (c *HMSClient) Use(interceptors ...Interceptor) {
+	c.interceptors = append(c.interceptors, interceptors...)
+}
+
+func (c *HMSClient) chain(send SendFunc) SendFunc {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		send = c.interceptors[i](send)
+	}
+	return send
+}
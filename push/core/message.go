@@ -19,52 +19,275 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/dafanasiev/go-hms-push/httpclient"
+	"github.com/dafanasiev/go-hms-push/logging"
+	"github.com/dafanasiev/go-hms-push/push/config"
 	"github.com/dafanasiev/go-hms-push/push/constant"
 	"github.com/dafanasiev/go-hms-push/push/model"
 	"github.com/dafanasiev/go-hms-push/push/verify"
+	"github.com/dafanasiev/go-hms-push/trace"
 )
 
+// WithClientMessageId tags the sends of ctx with an id of the caller's own, for correlating its logs with
+// HMS request ids
+// The id isn't sent to HMS, it's set on SendResult.ClientMessageId and in the trace metadata
+func WithClientMessageId(ctx context.Context, id string) context.Context {
+	return trace.WithMetadata(ctx, trace.Metadata{trace.ClientMessageIdKey: id})
+}
+
+type templateVarsKey struct{}
+
+// WithTemplateVars makes the sends of ctx expand the {name} placeholders of the notification titles and bodies
+// with vars, see model.Message.ExpandTemplate
+// The expansion happens on a copy before validation, so the size checks see the final text
+// With config.Config.StrictValidation a placeholder without a variable fails the send, it's sent as is otherwise
+func WithTemplateVars(ctx context.Context, vars map[string]string) context.Context {
+	return context.WithValue(ctx, templateVarsKey{}, vars)
+}
+
 // SendMessage sends a message to huawei cloud common
 // One of Token, Topic and Condition fields must be invoked in message
 // If validationOnly is set to true, the message can be verified by not sent to users
 func (c *HMSClient) SendMessage(ctx context.Context, msgRequest *model.MessageRequest) (*model.MessageResponse, error) {
-	result := &model.MessageResponse{}
+	ctx, cancel := c.withDefaultDeadline(ctx)
+	defer cancel()
 
-	err := verify.ValidateMessage(msgRequest.Message)
+	result, _, err := c.sendMessage(ctx, msgRequest)
+	return result, err
+}
+
+// Send sends a message like SendMessage and parses the response into a SendResult,
+// which carries the illegal tokens of token sends and the message id of topic and condition sends
+// Unlike SendMessage, a result code other than success or partial success is returned as *PushError
+// along with the result
+// Interceptors added with Use run around it
+func (c *HMSClient) Send(ctx context.Context, msgRequest *model.MessageRequest) (*model.SendResult, error) {
+	ctx, cancel := c.withDefaultDeadline(ctx)
+	defer cancel()
+
+	return c.chain(c.send)(ctx, msgRequest)
+}
+
+// SendRaw sends a message serialized elsewhere, e.g. by a template engine, with the same authorization,
+// retries and tracing as SendMessage
+// The message structure is checked with verify.ValidateRawMessage unless SkipValidation is set,
+// message defaults and interceptors don't apply
+func (c *HMSClient) SendRaw(ctx context.Context, msgRequest *model.RawMessageRequest) (*model.MessageResponse, error) {
+	ctx, cancel := c.withDefaultDeadline(ctx)
+	defer cancel()
+
+	if msgRequest == nil {
+		return nil, errors.New("message request must not be null")
+	}
+
+	ctx = c.withRequestId(ctx)
+	if !msgRequest.SkipValidation {
+		if err := verify.ValidateRawMessage(msgRequest.Message); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := json.Marshal(msgRequest)
 	if err != nil {
 		return nil, err
 	}
 
-	request, err := c.getSendMsgRequest(msgRequest)
+	urls, _, err := c.route(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	err = c.executeApiOperation(ctx, request, result)
-	if err != nil {
-		return result, err
+	request := &httpclient.PushRequest{
+		Method: http.MethodPost,
+		URL:    urls.send,
+		Body:   body,
 	}
+	if err = c.resetHTTPHeader(ctx, request); err != nil {
+		return nil, err
+	}
+
+	result := &model.MessageResponse{}
+	_, err = c.executeApiOperation(ctx, request, result)
 	return result, err
 }
 
-func (c *HMSClient) getSendMsgRequest(msgRequest *model.MessageRequest) (*httpclient.PushRequest, error) {
+// SendWithTimeout sends like Send with a context that expires after timeout
+// The timeout covers the whole send, retries and token refreshes included
+func (c *HMSClient) SendWithTimeout(timeout time.Duration, msgRequest *model.MessageRequest) (*model.SendResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.Send(ctx, msgRequest)
+}
+
+// SendDetailed sends a token message like Send and maps each of its tokens to a status,
+// see model.SendResult.TokenStatuses for how the statuses are approximated
+// A send failing without a response returns no statuses
+func (c *HMSClient) SendDetailed(ctx context.Context, msgRequest *model.MessageRequest) (map[string]model.TokenStatus, *model.SendResult, error) {
+	if msgRequest == nil || msgRequest.Message == nil || len(msgRequest.Message.Token) == 0 {
+		return nil, nil, errors.New("SendDetailed needs a message sent to tokens")
+	}
+
+	result, err := c.Send(ctx, msgRequest)
+	if result == nil {
+		return nil, nil, err
+	}
+	return result.TokenStatuses(msgRequest.Message.Token), result, err
+}
+
+func (c *HMSClient) send(ctx context.Context, msgRequest *model.MessageRequest) (*model.SendResult, error) {
+	start := c.clock.Now()
+	ctx = c.withRequestId(ctx)
+	result, resp, err := c.sendMessage(ctx, msgRequest)
+	if result == nil {
+		return nil, err
+	}
+
+	sendResult := model.NewSendResult(msgRequest.Message, result)
+	sendResult.AppId = c.appId
+	sendResult.ClientMessageId = trace.MetadataFrom(ctx)[trace.ClientMessageIdKey]
+	sendResult.ClientRequestId = trace.MetadataFrom(ctx)[trace.ClientRequestIdKey]
+	sendResult.Endpoint = endpointFrom(ctx)
+	if c.detailed {
+		sendResult.TotalDuration = c.clock.Now().Sub(start)
+	}
+	if resp != nil {
+		sendResult.Raw = append([]byte(nil), resp.Body...)
+		sendResult.AttemptCount = resp.Attempts
+		sendResult.Attempts = resp.AttemptDetails
+
+		// the body code decides, a 200 response may still carry a failure code
+		if err == nil && !isSendSuccess(result.Code) {
+			err = &PushError{StatusCode: resp.Status, Code: result.Code, Msg: result.Msg, RequestId: result.RequestId}
+		}
+	}
+	return sendResult, err
+}
+
+func (c *HMSClient) sendMessage(ctx context.Context, msgRequest *model.MessageRequest) (*model.MessageResponse, *httpclient.PushResponse, error) {
+	result := &model.MessageResponse{}
+	ctx = c.withRequestId(ctx)
+
+	if c.messageDefaults != nil && msgRequest != nil {
+		msgRequest = msgRequest.Clone()
+		c.messageDefaults.Apply(msgRequest.Message)
+	}
+
+	if vars, ok := ctx.Value(templateVarsKey{}).(map[string]string); ok && msgRequest != nil {
+		if c.messageDefaults == nil {
+			msgRequest = msgRequest.Clone()
+		}
+		unresolved := msgRequest.Message.ExpandTemplate(vars)
+		if c.strict && len(unresolved) > 0 {
+			return nil, nil, fmt.Errorf("unresolved template placeholders: %s", strings.Join(unresolved, ", "))
+		}
+	}
+
+	err := verify.ValidateMessage(msgRequest.Message)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.strict {
+		if conflicts := msgRequest.Message.Conflicts(); len(conflicts) > 0 {
+			return nil, nil, fmt.Errorf("conflicting message configs: %s", strings.Join(conflicts, "; "))
+		}
+	}
+
+	c.checkDataSize(ctx, msgRequest.Message)
+
+	if err = c.checkShouldSend(ctx, msgRequest.Message); err != nil {
+		return nil, nil, err
+	}
+
+	request, err := c.getSendMsgRequest(ctx, msgRequest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := c.executeApiOperation(ctx, request, result)
+	return result, resp, err
+}
+
+func (c *HMSClient) getSendMsgRequest(ctx context.Context, msgRequest *model.MessageRequest) (*httpclient.PushRequest, error) {
 	body, err := json.Marshal(msgRequest)
 	if err != nil {
 		return nil, err
 	}
 
+	urls, _, err := c.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	request := &httpclient.PushRequest{
 		Method: http.MethodPost,
-		URL:    fmt.Sprintf(constant.SendMessageFmt, c.endpoint, c.appId),
+		URL:    urls.send,
 		Body:   body,
-		Header: []httpclient.HTTPOption{
-			httpclient.SetHeader("Content-Type", "application/json;charset=utf-8"),
-			httpclient.SetHeader("Authorization", "Bearer "+c.token),
-		},
+	}
+	if err = c.resetHTTPHeader(ctx, request); err != nil {
+		return nil, err
 	}
 	return request, nil
 }
+
+func (c *HMSClient) checkShouldSend(ctx context.Context, message *model.Message) error {
+	if c.shouldSend == nil {
+		return nil
+	}
+
+	send, err := c.shouldSend(ctx, message)
+	if err != nil {
+		return fmt.Errorf("should send hook failed: %w", err)
+	}
+	if send {
+		return nil
+	}
+
+	if logging.Debug(ctx, c.logger) {
+		c.logger.LogAttrs(ctx, slog.LevelDebug, "hms push: send suppressed")
+	}
+	if t, ok := trace.FromContext(ctx); ok && t.MessageSuppressed != nil {
+		t.MessageSuppressed(trace.MetadataFrom(ctx))
+	}
+	return ErrSuppressed
+}
+
+// dataWarnAt returns the data size in bytes above which sends warn, zero for no warning
+func dataWarnAt(threshold float64) int {
+	if threshold < 0 {
+		return 0
+	}
+	if threshold == 0 {
+		threshold = config.DefaultDataSizeWarningThreshold
+	}
+	return int(threshold * constant.MaxDataSize)
+}
+
+// checkDataSize warns about a data payload nearing constant.MaxDataSize, ValidateMessage rejected larger ones
+func (c *HMSClient) checkDataSize(ctx context.Context, message *model.Message) {
+	if c.dataWarnAt == 0 {
+		return
+	}
+
+	size := len(message.Data)
+	if message.Android != nil && len(message.Android.Data) > size {
+		size = len(message.Android.Data)
+	}
+	if size <= c.dataWarnAt {
+		return
+	}
+
+	c.logger.LogAttrs(ctx, slog.LevelWarn, "hms push: data payload nears the size limit",
+		slog.Int("size", size), slog.Int("limit", constant.MaxDataSize))
+	if t, ok := trace.FromContext(ctx); ok && t.DataSizeWarning != nil {
+		t.DataSizeWarning(size, constant.MaxDataSize, trace.MetadataFrom(ctx))
+	}
+}
@@ -0,0 +1,121 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/core"
+	"github.com/dafanasiev/go-hms-push/push/model"
+	"github.com/dafanasiev/go-hms-push/push/pushtest"
+)
+
+func newTestClient(t *testing.T) (*core.HMSClient, *pushtest.Server) {
+	t.Helper()
+
+	server := pushtest.NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := core.NewHttpClient(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func tokenMessage(tokens ...string) *model.MessageRequest {
+	msgRequest := model.NewNotificationMsgRequest()
+	msgRequest.Message.Notification = &model.Notification{Title: "title", Body: "body"}
+	msgRequest.Message.Token = tokens
+	return msgRequest
+}
+
+func TestSendBodyCodeDecidesTheOutcome(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(s *pushtest.Server)
+		code    string
+		wantErr bool
+	}{
+		{"success", func(s *pushtest.Server) {}, constant.CodeSuccess, false},
+		{"partial success", func(s *pushtest.Server) { s.RespondPartial(1, "bad") }, constant.CodePartialSuccess, false},
+		{"failure code", func(s *pushtest.Server) {
+			s.RespondError(http.StatusOK, constant.CodeInvalidMessage, "invalid message")
+		}, constant.CodeInvalidMessage, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newTestClient(t)
+			tt.respond(server)
+
+			result, err := client.Send(context.Background(), tokenMessage("good", "bad"))
+			if result == nil {
+				t.Fatalf("result is nil, error %v", err)
+			}
+			if result.Code != tt.code {
+				t.Errorf("result code = %s, want %s", result.Code, tt.code)
+			}
+
+			var pushErr *core.PushError
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.As(err, &pushErr) {
+				t.Fatalf("error = %v, want *PushError", err)
+			}
+			if pushErr.StatusCode != http.StatusOK || pushErr.Code != tt.code {
+				t.Errorf("push error = %+v, want status 200 and code %s", pushErr, tt.code)
+			}
+		})
+	}
+}
+
+func TestMessageDefaultsDontOverrideTheMessage(t *testing.T) {
+	server := pushtest.NewServer()
+	defer server.Close()
+	client, err := core.NewHttpClient(server.Config(), core.WithMessageDefaults(&model.MessageDefaults{
+		CollapseKey: 5,
+		Urgency:     constant.DeliveryPriorityNormal,
+		TTL:         "86400s",
+		BiTag:       "default-tag",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgRequest := tokenMessage("token")
+	msgRequest.Message.Android = &model.AndroidConfig{TTL: "60s", BiTag: "send-tag"}
+	original := msgRequest.Clone()
+
+	if _, err = client.Send(context.Background(), msgRequest); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := server.SendRequests()[0].Message()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := model.AndroidConfig{CollapseKey: 5, Urgency: constant.DeliveryPriorityNormal, TTL: "60s", BiTag: "send-tag"}
+	if android := sent.Message.Android; android == nil || !reflect.DeepEqual(*android, want) {
+		t.Errorf("sent android config = %+v, want the message values over the defaults %+v", android, want)
+	}
+	if !reflect.DeepEqual(msgRequest, original) {
+		t.Errorf("message = %+v after the send, want it unchanged %+v", msgRequest.Message.Android, original.Message.Android)
+	}
+
+	// the android config the defaults need is created on the copy only
+	bare := tokenMessage("token")
+	if _, err = client.Send(context.Background(), bare); err != nil {
+		t.Fatal(err)
+	}
+	if bare.Message.Android != nil {
+		t.Errorf("android config = %+v after the send, want none", bare.Message.Android)
+	}
+}
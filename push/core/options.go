@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+
+	"github.com/dafanasiev/go-hms-push/httpclient"
+	auth "github.com/dafanasiev/go-hms-push/push/authention"
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+// ClientOption configures an HMSClient beyond what config.Config covers
+type ClientOption func(c *HMSClient)
+
+// WithMessageDefaults applies defaults to every message sent by the client
+// The defaults are merged into a copy, the caller's message is never modified
+func WithMessageDefaults(defaults *model.MessageDefaults) ClientOption {
+	return func(c *HMSClient) {
+		c.messageDefaults = defaults
+	}
+}
+
+// WithRetryPolicy replaces httpclient.DefaultRetryPolicy for the push requests of the client,
+// e.g. to retry a body code the default policy doesn't
+func WithRetryPolicy(policy httpclient.RetryPolicy) ClientOption {
+	return func(c *HMSClient) {
+		c.client.RetryConfig.RetryPolicy = policy
+	}
+}
+
+// WithContentType replaces the application/json;charset=utf-8 content type of the push requests,
+// NewHttpClient fails for a type other than application/json or a +json one
+func WithContentType(contentType string) ClientOption {
+	return func(c *HMSClient) {
+		c.contentType = contentType
+	}
+}
+
+// WithAccept replaces the application/json Accept header of the push requests, empty leaves the header out
+func WithAccept(accept string) ClientOption {
+	return func(c *HMSClient) {
+		c.accept = accept
+	}
+}
+
+// ShouldSend decides at the last moment whether a message is sent, e.g. to enforce quiet hours or opt-outs
+// It gets the message as it would be sent, defaults applied
+type ShouldSend func(ctx context.Context, msg *model.Message) (bool, error)
+
+// WithShouldSend runs shouldSend before every message send of the client, batches included
+// A false skips the HTTP call and the send returns ErrSuppressed, an error is returned as is
+// Suppressed sends reach interceptors as ErrSuppressed and the MessageSuppressed trace hook
+func WithShouldSend(shouldSend ShouldSend) ClientOption {
+	return func(c *HMSClient) {
+		c.shouldSend = shouldSend
+	}
+}
+
+// WithRequestIdGenerator replaces the random uuid the client generates for every logical send,
+// a nil generator stops the client from sending X-Request-Id
+func WithRequestIdGenerator(generate RequestIdGenerator) ClientOption {
+	return func(c *HMSClient) {
+		c.requestIdGenerator = generate
+	}
+}
+
+// WithTokenSource replaces the HMS auth client as the source of the access tokens, no auth endpoint is
+// contacted then, e.g. auth.StaticTokenSource for tests
+// The source is asked again for every request, config.Config.AuthorizationProvider takes precedence over it
+func WithTokenSource(source auth.TokenSource) ClientOption {
+	return func(c *HMSClient) {
+		c.tokenSource = source
+	}
+}
@@ -0,0 +1,320 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/clock"
+	"github.com/dafanasiev/go-hms-push/push/model"
+	"github.com/dafanasiev/go-hms-push/trace"
+)
+
+// DefaultOutboxMaxAttempts is the number of sends of an outbox entry before it's given up
+const DefaultOutboxMaxAttempts = 5
+
+// OutboxEntry is a message request persisted before it's sent
+type OutboxEntry struct {
+	Id      string
+	Request *model.MessageRequest
+	// ClientMessageId is the client message id of the original send, empty when it had none
+	ClientMessageId string
+	StoredAt        time.Time
+
+	// Attempts counts the failed sends of the entry, LastError describes the last one
+	Attempts  int
+	LastError string
+}
+
+// Outbox persists the messages of an OutboxSender until HMS accepts them, so the ones a crash interrupted
+// are sent again by the next process
+// Implementations must be safe for concurrent use, NewMemoryOutbox is a reference one
+type Outbox interface {
+	// Store persists a new entry, the entry isn't sent when it fails
+	Store(ctx context.Context, entry *OutboxEntry) error
+	// MarkSent acknowledges the entry, it must not be returned by Pending anymore
+	MarkSent(ctx context.Context, id string, result *model.SendResult) error
+	// MarkFailed records a failed send of the entry, incrementing its attempts
+	// An entry not to be retried must not be returned by Pending anymore
+	MarkFailed(ctx context.Context, id string, cause error, retry bool) error
+	// Pending returns the entries neither sent nor given up, oldest first
+	Pending(ctx context.Context) ([]*OutboxEntry, error)
+}
+
+// OutboxSender sends messages through an Outbox for at-least-once delivery across restarts
+// A message is stored first, then sent with Send, the entries left pending are sent again by Redeliver
+// HMS doesn't deduplicate messages, so a crash between the send and MarkSent delivers the message twice
+type OutboxSender struct {
+	client      *HMSClient
+	outbox      Outbox
+	maxAttempts int
+
+	mu       sync.Mutex
+	inflight map[string]struct{}
+}
+
+// NewOutboxSender returns a sender storing its messages in outbox, an entry is given up after
+// maxAttempts failed sends, DefaultOutboxMaxAttempts when it's 0
+// Sends rejected for a reason sending again can't fix, e.g. ErrSuppressed or a non transient result code,
+// are given up right away
+func (c *HMSClient) NewOutboxSender(outbox Outbox, maxAttempts int) (*OutboxSender, error) {
+	if outbox == nil {
+		return nil, errors.New("outbox must not be null")
+	}
+
+	if maxAttempts < 0 {
+		return nil, errors.New("maxAttempts can't be negative")
+	}
+	if maxAttempts == 0 {
+		maxAttempts = DefaultOutboxMaxAttempts
+	}
+
+	return &OutboxSender{
+		client:      c,
+		outbox:      outbox,
+		maxAttempts: maxAttempts,
+		inflight:    make(map[string]struct{}),
+	}, nil
+}
+
+// Send stores the message in the outbox and sends it like HMSClient.Send
+// The client message id of ctx, see WithClientMessageId, is kept in the entry for Redeliver to send with again,
+// without one the entry id is used instead
+// A failed send stays in the outbox for Redeliver unless it's given up
+func (s *OutboxSender) Send(ctx context.Context, msgRequest *model.MessageRequest) (*model.SendResult, error) {
+	if msgRequest == nil || msgRequest.Message == nil {
+		return nil, errors.New("message request must not be null")
+	}
+
+	id := s.newEntryId()
+	if id == "" {
+		return nil, errors.New("failed to generate an outbox entry id")
+	}
+
+	// held before the entry is visible to Pending, so that Redeliver can't send it meanwhile
+	if !s.acquire(id) {
+		return nil, errors.New("outbox entry " + id + " is already being sent")
+	}
+	defer s.release(id)
+
+	entry := &OutboxEntry{
+		Id:              id,
+		Request:         msgRequest.Clone(),
+		ClientMessageId: trace.MetadataFrom(ctx)[trace.ClientMessageIdKey],
+		StoredAt:        s.client.clock.Now(),
+	}
+	if err := s.outbox.Store(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	if entry.ClientMessageId == "" {
+		ctx = WithClientMessageId(ctx, entry.Id)
+	}
+	return s.dispatch(ctx, entry)
+}
+
+// newEntryId uses the request id generator of the client, falling back to NewRequestId
+// when there is none or it returns an empty id
+func (s *OutboxSender) newEntryId() string {
+	if generate := s.client.requestIdGenerator; generate != nil {
+		if id := generate(); id != "" {
+			return id
+		}
+	}
+	return NewRequestId()
+}
+
+// Redeliver sends the pending entries of the outbox once, skipping the ones being sent by Send,
+// and returns the number of entries sent successfully
+// Call it on startup to recover the sends of a previous process, Run calls it periodically
+func (s *OutboxSender) Redeliver(ctx context.Context) (int, error) {
+	entries, err := s.outbox.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			return sent, err
+		}
+		if !s.acquire(entry.Id) {
+			continue
+		}
+
+		clientMessageId := entry.ClientMessageId
+		if clientMessageId == "" {
+			clientMessageId = entry.Id
+		}
+		_, sendErr := s.dispatch(WithClientMessageId(ctx, clientMessageId), entry)
+		s.release(entry.Id)
+		if sendErr == nil {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// Run calls Redeliver every interval until ctx is done, it returns the error of ctx
+// or the first error of the outbox
+func (s *OutboxSender) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+
+	for {
+		if _, err := s.Redeliver(ctx); err != nil {
+			return err
+		}
+		if err := clock.Sleep(ctx, s.client.clock, interval); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *OutboxSender) dispatch(ctx context.Context, entry *OutboxEntry) (*model.SendResult, error) {
+	result, err := s.client.Send(ctx, entry.Request)
+	if err == nil {
+		if markErr := s.outbox.MarkSent(ctx, entry.Id, result); markErr != nil {
+			return result, markErr
+		}
+		return result, nil
+	}
+
+	retry := outboxRetryable(err) && entry.Attempts+1 < s.maxAttempts
+	if markErr := s.outbox.MarkFailed(ctx, entry.Id, err, retry); markErr != nil {
+		return result, errors.Join(err, markErr)
+	}
+	return result, err
+}
+
+func (s *OutboxSender) acquire(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.inflight[id]; ok {
+		return false
+	}
+	s.inflight[id] = struct{}{}
+	return true
+}
+
+func (s *OutboxSender) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inflight, id)
+}
+
+// outboxRetryable tells the failures worth sending the entry again, the number of attempts bounds the others
+func outboxRetryable(err error) bool {
+	if errors.Is(err, ErrSuppressed) {
+		return false
+	}
+
+	var pushErr *PushError
+	if errors.As(err, &pushErr) {
+		return model.IsTransientCode(pushErr.Code)
+	}
+	return true
+}
+
+// MemoryOutbox is an Outbox kept in memory, it doesn't survive restarts
+// It's a reference for implementations backed by a database and a stand-in for tests
+type MemoryOutbox struct {
+	mu      sync.Mutex
+	order   []string
+	pending map[string]*OutboxEntry
+	failed  []*OutboxEntry
+}
+
+// NewMemoryOutbox returns an empty memory outbox
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{pending: make(map[string]*OutboxEntry)}
+}
+
+// Store implements Outbox
+func (o *MemoryOutbox) Store(_ context.Context, entry *OutboxEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.pending[entry.Id]; ok {
+		return errors.New("outbox entry " + entry.Id + " already stored")
+	}
+
+	stored := *entry
+	o.pending[entry.Id] = &stored
+	o.order = append(o.order, entry.Id)
+	return nil
+}
+
+// MarkSent implements Outbox
+func (o *MemoryOutbox) MarkSent(_ context.Context, id string, _ *model.SendResult) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.remove(id)
+	return nil
+}
+
+// MarkFailed implements Outbox, entries given up are kept for Failed
+func (o *MemoryOutbox) MarkFailed(_ context.Context, id string, cause error, retry bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry, ok := o.pending[id]
+	if !ok {
+		return nil
+	}
+
+	entry.Attempts++
+	if cause != nil {
+		entry.LastError = cause.Error()
+	}
+	if !retry {
+		o.remove(id)
+		o.failed = append(o.failed, entry)
+	}
+	return nil
+}
+
+// Pending implements Outbox, it returns copies of the entries
+func (o *MemoryOutbox) Pending(_ context.Context) ([]*OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries := make([]*OutboxEntry, 0, len(o.order))
+	for _, id := range o.order {
+		entry := *o.pending[id]
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+// Failed returns copies of the entries given up, in the order they were
+func (o *MemoryOutbox) Failed() []*OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries := make([]*OutboxEntry, 0, len(o.failed))
+	for _, failed := range o.failed {
+		entry := *failed
+		entries = append(entries, &entry)
+	}
+	return entries
+}
+
+func (o *MemoryOutbox) remove(id string) {
+	if _, ok := o.pending[id]; !ok {
+		return
+	}
+
+	delete(o.pending, id)
+	for i, stored := range o.order {
+		if stored == id {
+			o.order = append(o.order[:i], o.order[i+1:]...)
+			break
+		}
+	}
+}
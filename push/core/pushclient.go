@@ -21,26 +21,68 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/dafanasiev/go-hms-push/clock"
+	"github.com/dafanasiev/go-hms-push/logging"
 	auth "github.com/dafanasiev/go-hms-push/push/authention"
 	"github.com/dafanasiev/go-hms-push/push/config"
 
 	"github.com/dafanasiev/go-hms-push/httpclient"
 	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/model"
+	"github.com/dafanasiev/go-hms-push/trace"
+)
+
+const (
+	defaultContentType = "application/json;charset=utf-8"
+	defaultAccept      = "application/json"
 )
 
 type HMSClient struct {
-	endpoint   string
-	appId      string
-	token      string
-	authClient *auth.AuthClient
-	client     *httpclient.HTTPClient
+	appId        string
+	authClient   *auth.AuthClient
+	tokenSource  auth.TokenSource
+	authProvider func(ctx context.Context) (string, error)
+	client       *httpclient.HTTPClient
+	clock        clock.Clock
+	logger       *slog.Logger
+	interceptors []Interceptor
+	detailed     bool
+	strict       bool
+	deadline     time.Duration
+	dataWarnAt   int
+
+	messageDefaults *model.MessageDefaults
+	shouldSend      ShouldSend
+	contentType     string
+	accept          string
+
+	requestIdGenerator RequestIdGenerator
+
+	invalidationThreshold int32
+	// consecutive sends rejected for authorization reasons
+	authFailures int32
+
+	quotaMu sync.Mutex
+	quota   model.QuotaStatus
+
+	urls *clientURLs
+	// the alternate endpoints of WithAlternateEndpoint by name, built once the options are applied
+	endpoints        map[string]*endpoint
+	pendingEndpoints []*endpointSpec
 }
 
 // NewClient creates a instance of the huawei cloud common client
 // It's contained in huawei cloud app and provides service through huawei cloud app
-func NewHttpClient(c *config.Config) (*HMSClient, error) {
+func NewHttpClient(c *config.Config, opts ...ClientOption) (*HMSClient, error) {
 	if c.AppId == "" {
 		return nil, errors.New("appId can't be empty")
 	}
@@ -49,6 +91,19 @@ func NewHttpClient(c *config.Config) (*HMSClient, error) {
 		return nil, errors.New("pushUrl can't be empty")
 	}
 
+	if c.PathTemplate != "" && !strings.Contains(c.PathTemplate, constant.AppIdPlaceholder) {
+		return nil, fmt.Errorf("pathTemplate must contain the %s placeholder", constant.AppIdPlaceholder)
+	}
+
+	if c.StatsPathTemplate != "" && !strings.Contains(c.StatsPathTemplate, constant.AppIdPlaceholder) {
+		return nil, fmt.Errorf("statsPathTemplate must contain the %s placeholder", constant.AppIdPlaceholder)
+	}
+
+	urls, err := newClientURLs(c)
+	if err != nil {
+		return nil, err
+	}
+
 	httpClientCfg, err := httpclient.NewHTTPClientConfig(c)
 	if err != nil {
 		return nil, err
@@ -56,84 +111,274 @@ func NewHttpClient(c *config.Config) (*HMSClient, error) {
 
 	client, err := httpclient.NewHTTPClient(httpClientCfg)
 	if err != nil {
-		return nil, errors.New("failed to get http client")
+		return nil, fmt.Errorf("failed to get http client: %w", err)
 	}
 
-	authClient, err := auth.NewAuthClient(c)
-	if err != nil {
+	hmsClient := &HMSClient{
+		appId:        c.AppId,
+		authProvider: c.AuthorizationProvider,
+		client:       client,
+		clock:        clock.OrReal(c.Clock),
+		logger:       logging.OrDiscard(c.Logger),
+		detailed:     c.DetailedResults,
+		strict:       c.StrictValidation,
+		deadline:     c.DefaultDeadline,
+		dataWarnAt:   dataWarnAt(c.DataSizeWarningThreshold),
+
+		invalidationThreshold: int32(c.TokenInvalidationThreshold),
+
+		contentType: defaultContentType,
+		accept:      defaultAccept,
+
+		requestIdGenerator: NewRequestId,
+
+		urls: urls,
+	}
+	for _, opt := range opts {
+		opt(hmsClient)
+	}
+
+	if !isJSONMediaType(hmsClient.contentType) {
+		return nil, fmt.Errorf("content type %q isn't json, the request bodies are", hmsClient.contentType)
+	}
+
+	if err = hmsClient.buildEndpoints(c, httpClientCfg); err != nil {
 		return nil, err
 	}
 
-	token, err := authClient.GetAuthToken(context.Background())
-	if err != nil {
-		return nil, errors.New("refresh token fail")
+	// the auth client is only needed without a token source of the caller's own
+	if hmsClient.authProvider == nil && hmsClient.tokenSource == nil {
+		authClient, err := auth.NewAuthClient(c)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = authClient.Token(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("refresh token fail: %w", err)
+		}
+		hmsClient.authClient = authClient
+		hmsClient.tokenSource = authClient
 	}
+	return hmsClient, nil
+}
+
+// Prime fetches a new access token and caches it for the next sends, so a server can pay the
+// token latency and check its credentials at startup
+// A failure is returned as *auth.AuthError
+// With config.Config.AuthorizationProvider or WithTokenSource, the provider or source is called once instead
+// and nothing is cached
+func (c *HMSClient) Prime(ctx context.Context) error {
+	ctx, cancel := c.withDefaultDeadline(ctx)
+	defer cancel()
 
-	return &HMSClient{
-		endpoint:   c.PushUrl,
-		appId:      c.AppId,
-		token:      token,
-		authClient: authClient,
-		client:     client,
-	}, nil
+	if c.authProvider != nil || c.authClient == nil {
+		_, err := c.getAuthorization(ctx)
+		return err
+	}
+
+	_, err := c.authClient.Refresh(ctx)
+	return err
 }
 
-func (c *HMSClient) refreshToken() error {
+// RevokeToken revokes and drops the cached access token, see auth.AuthClient.RevokeToken
+// It does nothing with config.Config.AuthorizationProvider or WithTokenSource
+func (c *HMSClient) RevokeToken(ctx context.Context) error {
+	ctx, cancel := c.withDefaultDeadline(ctx)
+	defer cancel()
+
 	if c.authClient == nil {
-		return errors.New("can't refresh token because getting auth client fail")
+		return nil
+	}
+	return c.authClient.RevokeToken(ctx)
+}
+
+// withDefaultDeadline bounds ctx by config.Config.DefaultDeadline unless ctx has a deadline of its own
+func (c *HMSClient) withDefaultDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.deadline <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.deadline)
+}
+
+func (c *HMSClient) refreshToken(ctx context.Context) error {
+	if c.authProvider != nil || c.authClient == nil {
+		// the provider or the token source of WithTokenSource is asked again for every request
+		return nil
 	}
 
-	token, err := c.authClient.GetAuthToken(context.Background())
+	if logging.Debug(ctx, c.logger) {
+		c.logger.LogAttrs(ctx, slog.LevelDebug, "hms push: refreshing the access token rejected by HMS")
+	}
+	_, err := c.authClient.Refresh(ctx)
 	if err != nil {
-		return errors.New("refresh token fail")
+		return fmt.Errorf("refresh token fail: %w", err)
+	}
+	return nil
+}
+
+func (c *HMSClient) resetHTTPHeader(ctx context.Context, request *httpclient.PushRequest) error {
+	header, err := c.getHTTPHeader(ctx)
+	if err != nil {
+		return err
 	}
 
-	c.token = token
+	request.Header = header
+	// a retry of the request picks up a token refreshed in the meantime
+	request.RefreshHeaders = func() []httpclient.HTTPOption {
+		if header, err := c.getHTTPHeader(ctx); err == nil {
+			return header
+		}
+		return request.Header
+	}
 	return nil
 }
 
-func (c *HMSClient) resetHTTPHeader(request *httpclient.PushRequest) *httpclient.PushRequest {
-	request.Header = []httpclient.HTTPOption{
-		httpclient.SetHeader("Content-Type", "application/json;charset=utf-8"),
-		httpclient.SetHeader("Authorization", "Bearer "+c.token),
+func (c *HMSClient) getHTTPHeader(ctx context.Context) ([]httpclient.HTTPOption, error) {
+	authorization, err := c.getAuthorization(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	header := []httpclient.HTTPOption{
+		httpclient.SetHeader("Content-Type", c.contentType),
+		httpclient.SetHeader("Authorization", authorization),
+	}
+	if c.accept != "" {
+		header = append(header, httpclient.SetHeader("Accept", c.accept))
 	}
-	return request
+	if id := trace.MetadataFrom(ctx)[trace.ClientRequestIdKey]; id != "" {
+		header = append(header, httpclient.SetHeader(httpclient.HeaderRequestId, id))
+	}
+	return header, nil
 }
 
-func (c *HMSClient) executeApiOperation(ctx context.Context, request *httpclient.PushRequest, responsePointer interface{}) error {
-	err := c.sendHttpRequest(ctx, request, responsePointer)
+// isJSONMediaType reports whether contentType is application/json or a +json type
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return err
+		return false
 	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
 
-	// if need to retry for token timeout or other reasons
-	retry, err := c.isNeedRetry(responsePointer)
+func (c *HMSClient) getAuthorization(ctx context.Context) (string, error) {
+	if c.authProvider != nil {
+		authorization, err := c.authProvider(ctx)
+		if err != nil {
+			return "", &auth.AuthError{Err: err}
+		}
+		return authorization, nil
+	}
+
+	token, err := c.tokenSource.Token(ctx)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("refresh token fail: %w", err)
+	}
+	return "Bearer " + token, nil
+}
+
+func (c *HMSClient) executeApiOperation(ctx context.Context, request *httpclient.PushRequest, responsePointer interface{}) (*httpclient.PushResponse, error) {
+	resp, err := c.sendHttpRequest(ctx, request, responsePointer)
+
+	var retry bool
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		// the access token was rejected, refresh it and send again once
+		if err = c.refreshToken(ctx); err != nil {
+			return resp, err
+		}
+		retry = true
+	case err != nil:
+		return resp, err
+	default:
+		// if need to retry for token timeout or other reasons
+		retry, err = c.isNeedRetry(ctx, responsePointer)
+		if err != nil {
+			return resp, err
+		}
 	}
 
 	if retry {
-		c.resetHTTPHeader(request)
-		err = c.sendHttpRequest(ctx, request, responsePointer)
-		return err
+		if err = c.resetHTTPHeader(ctx, request); err != nil {
+			return resp, err
+		}
+		retried, err := c.sendHttpRequest(ctx, request, responsePointer)
+		if retried != nil && resp != nil && c.detailed {
+			retried.AttemptDetails = append(append([]httpclient.Attempt(nil), resp.AttemptDetails...), retried.AttemptDetails...)
+		}
+		return retried, err
 	}
-	return err
+	return resp, err
 }
 
-func (c *HMSClient) sendHttpRequest(ctx context.Context, request *httpclient.PushRequest, responsePointer interface{}) error {
-	resp, err := c.client.DoHttpRequest(ctx, request)
+func (c *HMSClient) sendHttpRequest(ctx context.Context, request *httpclient.PushRequest, responsePointer interface{}) (*httpclient.PushResponse, error) {
+	_, client, err := c.route(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	resp, err := client.DoHttpRequest(ctx, request)
+	c.observeQuota(resp, err)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.Status == http.StatusUnauthorized {
+		c.trackAuthFailure(ctx, true)
+		return resp, ErrUnauthorized
 	}
 
 	if err = json.Unmarshal(resp.Body, responsePointer); err != nil {
-		return err
+		// a throttled request may come back without a json body
+		if resp.Status == http.StatusTooManyRequests {
+			return resp, c.newQuotaError(resp, "", "")
+		}
+		return resp, newResponseParseError(resp, err)
+	}
+
+	code, msg := responseCode(responsePointer)
+	c.trackAuthFailure(ctx, code == constant.CodePermissionDenied)
+
+	if isQuotaExceeded(resp.Status, code) {
+		return resp, c.newQuotaError(resp, code, msg)
+	}
+	return resp, nil
+}
+
+// trackAuthFailure counts consecutive sends rejected for authorization reasons and drops
+// the cached token once there are TokenInvalidationThreshold of them
+// CodeAllTokensInvalid isn't one, it's about the device tokens of the message, not the access token
+func (c *HMSClient) trackAuthFailure(ctx context.Context, rejected bool) {
+	if c.invalidationThreshold <= 0 || c.authClient == nil {
+		return
+	}
+
+	if !rejected {
+		atomic.StoreInt32(&c.authFailures, 0)
+		return
+	}
+
+	failures := atomic.AddInt32(&c.authFailures, 1)
+	if failures < c.invalidationThreshold || !atomic.CompareAndSwapInt32(&c.authFailures, failures, 0) {
+		return
+	}
+
+	c.authClient.Invalidate()
+	if logging.Debug(ctx, c.logger) {
+		c.logger.LogAttrs(ctx, slog.LevelDebug, "hms push: dropping the access token after rejected sends",
+			slog.Int("rejected", int(failures)))
+	}
+	if t, ok := trace.FromContext(ctx); ok && t.TokenInvalidated != nil {
+		t.TokenInvalidated(int(failures))
 	}
-	return nil
 }
 
 // if token is timeout or error or other reason, need to refresh token and send again
-func (c *HMSClient) isNeedRetry(responsePointer interface{}) (bool, error) {
+func (c *HMSClient) isNeedRetry(ctx context.Context, responsePointer interface{}) (bool, error) {
 	tokenError, err := isTokenError(responsePointer)
 	if err != nil {
 		return false, err
@@ -143,7 +388,7 @@ func (c *HMSClient) isNeedRetry(responsePointer interface{}) (bool, error) {
 		return false, nil
 	}
 
-	err = c.refreshToken()
+	err = c.refreshToken(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -179,3 +424,46 @@ func checkParamStructPtr(structPtr interface{}) (reflect.Value, reflect.Type, bo
 	}
 	return val, t, true
 }
+
+// responseCode returns the Code and Msg fields of the struct responsePointer points to
+func responseCode(responsePointer interface{}) (string, string) {
+	val, _, ok := checkParamStructPtr(responsePointer)
+	if !ok {
+		return "", ""
+	}
+	return val.Elem().FieldByName("Code").String(), val.Elem().FieldByName("Msg").String()
+}
+
+type clientURLs struct {
+	// base is PushUrl without its trailing slash
+	base        string
+	send        string
+	stats       string
+	subscribe   string
+	unsubscribe string
+	listTopics  string
+}
+
+// newClientURLs builds the api urls once, so a malformed PushUrl fails the client creation rather than the sends
+func newClientURLs(c *config.Config) (*clientURLs, error) {
+	var (
+		urls = clientURLs{base: strings.TrimRight(c.PushUrl, "/")}
+		err  error
+	)
+	if urls.send, err = c.SendURL(); err != nil {
+		return nil, err
+	}
+	if urls.stats, err = c.StatsURL(); err != nil {
+		return nil, err
+	}
+	if urls.subscribe, err = c.SubscribeTopicURL(); err != nil {
+		return nil, err
+	}
+	if urls.unsubscribe, err = c.UnsubscribeTopicURL(); err != nil {
+		return nil, err
+	}
+	if urls.listTopics, err = c.ListTopicsURL(); err != nil {
+		return nil, err
+	}
+	return &urls, nil
+}
@@ -0,0 +1,191 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/core"
+	"github.com/dafanasiev/go-hms-push/push/model"
+	"github.com/dafanasiev/go-hms-push/push/pushtest"
+	"github.com/dafanasiev/go-hms-push/trace"
+)
+
+func TestTokenInvalidationThreshold(t *testing.T) {
+	tests := []struct {
+		name        string
+		rejected    *pushtest.Response
+		wantTokens  int
+		invalidated bool
+	}{
+		// a 401 refreshes the token and sends again on its own, the second 401 drops the refreshed token
+		{"unauthorized", &pushtest.Response{Status: http.StatusUnauthorized,
+			Body: &model.MessageResponse{Code: constant.CodePermissionDenied}}, 3, true},
+		{"permission denied", &pushtest.Response{
+			Body: &model.MessageResponse{Code: constant.CodePermissionDenied}}, 2, true},
+		// the device tokens are invalid, dropping the access token wouldn't help
+		{"all tokens invalid", &pushtest.Response{
+			Body: &model.MessageResponse{Code: constant.CodeAllTokensInvalid}}, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := pushtest.NewServer()
+			defer server.Close()
+
+			conf := server.Config()
+			conf.TokenInvalidationThreshold = 2
+			client, err := core.NewHttpClient(conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var invalidated []int
+			ctx := trace.NewContext(context.Background(), trace.HmsTrace{
+				TokenInvalidated: func(failures int) { invalidated = append(invalidated, failures) },
+			})
+
+			// the token is revoked server side before its expiry, every send is rejected until it's replaced
+			server.Respond(tt.rejected, tt.rejected)
+			for i := 0; i < 2; i++ {
+				_, _ = client.Send(ctx, tokenMessage("token"))
+			}
+			if _, err = client.Send(ctx, tokenMessage("token")); err != nil {
+				t.Fatal(err)
+			}
+
+			var wantInvalidated []int
+			if tt.invalidated {
+				wantInvalidated = []int{2}
+			}
+			if server.TokensIssued() != tt.wantTokens {
+				t.Errorf("tokens issued = %d, want %d", server.TokensIssued(), tt.wantTokens)
+			}
+			if len(invalidated) != len(wantInvalidated) || (len(invalidated) > 0 && invalidated[0] != wantInvalidated[0]) {
+				t.Errorf("TokenInvalidated calls = %v, want %v", invalidated, wantInvalidated)
+			}
+		})
+	}
+}
+
+func TestUnauthorizedRefreshesAndResendsOnce(t *testing.T) {
+	unauthorized := &pushtest.Response{Status: http.StatusUnauthorized}
+	tests := []struct {
+		name      string
+		responses []*pushtest.Response
+		wantErr   error
+	}{
+		{"accepted after the refresh", []*pushtest.Response{unauthorized}, nil},
+		// the refreshed token is rejected as well, another refresh wouldn't help
+		{"rejected after the refresh", []*pushtest.Response{unauthorized, unauthorized, unauthorized}, core.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newTestClient(t)
+			server.Respond(tt.responses...)
+
+			_, err := client.Send(context.Background(), tokenMessage("token"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			if server.TokensIssued() != 2 {
+				t.Errorf("tokens issued = %d, want the first one and a single refresh", server.TokensIssued())
+			}
+			sends := server.SendRequests()
+			if len(sends) != 2 {
+				t.Fatalf("sends = %d, want the request and a single resend", len(sends))
+			}
+			if got := sends[1].Header.Get("Authorization"); got != "Bearer pushtest-token-2" {
+				t.Errorf("resend authorization = %q, want the refreshed token", got)
+			}
+		})
+	}
+}
+
+func TestDefaultDeadline(t *testing.T) {
+	shorter := 50 * time.Millisecond
+	tests := []struct {
+		name            string
+		defaultDeadline time.Duration
+		ctxTimeout      time.Duration
+		wantDeadline    bool
+		want            time.Duration
+	}{
+		{"applied to a context without deadline", time.Minute, 0, true, time.Minute},
+		{"shorter context deadline kept", time.Minute, shorter, true, shorter},
+		{"disabled", 0, 0, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := pushtest.NewServer()
+			defer server.Close()
+
+			conf := server.Config()
+			conf.DefaultDeadline = tt.defaultDeadline
+			client, err := core.NewHttpClient(conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var deadline time.Time
+			var hasDeadline bool
+			client.Use(func(next core.SendFunc) core.SendFunc {
+				return func(ctx context.Context, msgRequest *model.MessageRequest) (*model.SendResult, error) {
+					deadline, hasDeadline = ctx.Deadline()
+					return &model.SendResult{}, nil
+				}
+			})
+
+			start := time.Now()
+			ctx := context.Background()
+			if tt.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+				defer cancel()
+			}
+
+			if _, err = client.Send(ctx, tokenMessage("token")); err != nil {
+				t.Fatal(err)
+			}
+			end := time.Now()
+			if hasDeadline != tt.wantDeadline {
+				t.Fatalf("has deadline = %v, want %v", hasDeadline, tt.wantDeadline)
+			}
+			if tt.wantDeadline && (deadline.Before(start.Add(tt.want)) || deadline.After(end.Add(tt.want))) {
+				t.Errorf("deadline %v after the start, want %v", deadline.Sub(start), tt.want)
+			}
+		})
+	}
+}
+
+func TestPrimeCachesTheTokenForTheSends(t *testing.T) {
+	client, server := newTestClient(t)
+	// NewHttpClient fetched the first token, Prime replaces it
+	if err := client.Prime(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if server.TokensIssued() != 2 {
+		t.Fatalf("tokens issued = %d after Prime, want 2", server.TokensIssued())
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Send(context.Background(), tokenMessage("token")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if auth := len(server.Requests()) - len(server.SendRequests()); auth != 2 || server.TokensIssued() != 2 {
+		t.Errorf("auth requests = %d, tokens issued = %d, want no fetch after Prime", auth, server.TokensIssued())
+	}
+	for i, send := range server.SendRequests() {
+		if got := send.Header.Get("Authorization"); got != "Bearer pushtest-token-2" {
+			t.Errorf("send %d authorization = %q, want the primed token", i, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/httpclient"
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+// QuotaStatus returns the quota estimate from the responses seen so far
+// HMS documents no quota headers, so it relies on rate limit headers a gateway may add
+// and on the quota exceeded answers, Known is false until one of them was seen
+func (c *HMSClient) QuotaStatus() model.QuotaStatus {
+	c.quotaMu.Lock()
+	defer c.quotaMu.Unlock()
+
+	status := c.quota
+	if status.Exceeded && !status.ResetAt.IsZero() && !c.clock.Now().Before(status.ResetAt) {
+		// the reset has passed, nothing is known of the new window yet
+		return model.QuotaStatus{}
+	}
+	return status
+}
+
+// observeQuota updates the quota estimate from a response, ignoring the ones without a quota signal
+func (c *HMSClient) observeQuota(resp *httpclient.PushResponse, err error) {
+	if resp == nil {
+		var exhausted *httpclient.RetryExhaustedError
+		if !errors.As(err, &exhausted) || exhausted.LastResponse == nil {
+			return
+		}
+		resp = exhausted.LastResponse
+	}
+
+	now := c.clock.Now()
+	remaining, hasRemaining := resp.RateLimitRemaining()
+	var resetAt time.Time
+	if reset, ok := resp.RateLimitReset(); ok {
+		resetAt = now.Add(reset)
+	}
+	exceeded := isQuotaExceeded(resp.Status, resp.Code)
+	if exceeded && resetAt.IsZero() {
+		if retryAfter, ok := resp.RetryAfterAt(now); ok {
+			resetAt = now.Add(retryAfter)
+		}
+	}
+
+	if !hasRemaining && !exceeded {
+		if resp.Status == http.StatusOK {
+			// an accepted send proves an exhausted quota was reset, but tells nothing of what is left
+			c.quotaMu.Lock()
+			defer c.quotaMu.Unlock()
+			if c.quota.Exceeded {
+				c.quota = model.QuotaStatus{}
+			}
+		}
+		return
+	}
+
+	status := model.QuotaStatus{Known: true, Remaining: remaining, ResetAt: resetAt, Exceeded: exceeded, ObservedAt: now}
+	if exceeded {
+		status.Remaining = 0
+	}
+
+	c.quotaMu.Lock()
+	defer c.quotaMu.Unlock()
+	c.quota = status
+}
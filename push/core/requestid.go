@@ -0,0 +1,37 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/dafanasiev/go-hms-push/trace"
+)
+
+// RequestIdGenerator returns the id of a logical send, sent in the X-Request-Id header
+type RequestIdGenerator func() string
+
+// NewRequestId returns a random version 4 uuid, the default RequestIdGenerator
+func NewRequestId() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// withRequestId attaches a request id to the trace metadata of ctx, unless it already has one,
+// so that the retries and the resend after a token refresh carry the id of their send
+func (c *HMSClient) withRequestId(ctx context.Context) context.Context {
+	if c.requestIdGenerator == nil || trace.MetadataFrom(ctx)[trace.ClientRequestIdKey] != "" {
+		return ctx
+	}
+
+	id := c.requestIdGenerator()
+	if id == "" {
+		return ctx
+	}
+	return trace.WithMetadata(ctx, trace.Metadata{trace.ClientRequestIdKey: id})
+}
@@ -0,0 +1,71 @@
+package core_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/httpclient"
+	"github.com/dafanasiev/go-hms-push/push/core"
+	"github.com/dafanasiev/go-hms-push/push/pushtest"
+	"github.com/dafanasiev/go-hms-push/trace"
+)
+
+func TestClientIdsReachHeadersHooksAndResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestId string
+	}{
+		{"caller request id", "caller-request"},
+		{"generated request id", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newTestClient(t)
+			// the resend after the token refresh must carry the id of its send
+			server.Respond(&pushtest.Response{Status: http.StatusUnauthorized})
+
+			var metadata []trace.Metadata
+			ctx := trace.NewContext(context.Background(), trace.HmsTrace{
+				GotMetadata: func(md trace.Metadata) { metadata = append(metadata, md) },
+			})
+			ctx = core.WithClientMessageId(ctx, "caller-message")
+			if tt.requestId != "" {
+				ctx = trace.WithMetadata(ctx, trace.Metadata{trace.ClientRequestIdKey: tt.requestId})
+			}
+
+			result, err := client.Send(ctx, tokenMessage("token"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			requestId := result.ClientRequestId
+			if requestId == "" || (tt.requestId != "" && requestId != tt.requestId) {
+				t.Fatalf("result request id = %q, want %q or a generated one", requestId, tt.requestId)
+			}
+			if result.ClientMessageId != "caller-message" {
+				t.Errorf("result message id = %q, want caller-message", result.ClientMessageId)
+			}
+
+			sends := server.SendRequests()
+			if len(sends) != 2 {
+				t.Fatalf("sends = %d, want the request and its resend", len(sends))
+			}
+			for i, send := range sends {
+				if got := send.Header.Get(httpclient.HeaderRequestId); got != requestId {
+					t.Errorf("send %d %s = %q, want %q", i, httpclient.HeaderRequestId, got, requestId)
+				}
+			}
+
+			if len(metadata) == 0 {
+				t.Fatal("GotMetadata wasn't called")
+			}
+			for i, md := range metadata {
+				if md[trace.ClientRequestIdKey] != requestId || md[trace.ClientMessageIdKey] != "caller-message" {
+					t.Errorf("metadata %d = %v, want request id %q and message id caller-message", i, md, requestId)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+// QueryMessageStatus queries the delivery statistics of the message sent with requestId
+// It needs config.Config.StatsPathTemplate, ErrStatsNotConfigured is returned without it,
+// and *StatsUnavailableError when the app isn't entitled to statistics
+func (c *HMSClient) QueryMessageStatus(ctx context.Context, requestId string) (*model.MessageStats, error) {
+	ctx, cancel := c.withDefaultDeadline(ctx)
+	defer cancel()
+
+	urls, _, err := c.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if urls.stats == "" {
+		return nil, ErrStatsNotConfigured
+	}
+
+	if requestId == "" {
+		return nil, errors.New("requestId can't be empty")
+	}
+
+	request, err := c.newApiRequest(ctx, urls.stats, &model.MessageStatsRequest{RequestId: requestId})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.MessageStats{}
+	resp, err := c.executeApiOperation(ctx, request, result)
+	if resp != nil && (resp.Status == http.StatusForbidden || resp.Status == http.StatusNotFound) {
+		return nil, &StatsUnavailableError{StatusCode: resp.Status, Body: resp.Body}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Code != constant.CodeSuccess {
+		return result, fmt.Errorf("failed to query message status: code %s: %s", result.Code, result.Msg)
+	}
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+// SendFromReader sends the message of msgRequest to the tokens of a stream, e.g. a database cursor,
+// reading them lazily into chunks of ChunkSize tokens so that memory stays constant whatever the audience
+// The producer sends the tokens on the tokens channel and closes it at the end of the stream, tokensErr,
+// which may be nil, is called once it's closed and reports why the stream ended, like sql.Rows.Err
+// Up to Concurrency chunks are sent at once, each outcome is handed to onChunk, which may be nil,
+// one at a time in completion order; DeduplicateTokens only applies within a chunk and
+// MaxIllegalTokens is ignored since no token is kept
+// An error of the stream doesn't discard the tokens read before it, they're still sent and the returned
+// error wraps it, Tokens of the result tells how far the stream got
+// Once ctx is done the channel isn't read anymore and no further chunk is sent, the producer should watch ctx
+// too; the tokens read but unsent are in the result Unsent
+func (c *HMSClient) SendFromReader(ctx context.Context, msgRequest *model.MessageRequest, tokens <-chan string, tokensErr func() error, onChunk func(*model.ChunkResult), opts ...BatchOption) (*model.StreamResult, error) {
+	o, err := newBatchOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if msgRequest == nil {
+		return nil, errors.New("message request must not be null")
+	}
+
+	if tokens == nil {
+		return nil, errors.New("tokens must not be null")
+	}
+
+	result := &model.StreamResult{}
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		slots    = make(chan struct{}, o.concurrency)
+	)
+
+	dispatch := func(chunkTokens []string) {
+		if o.deduplicate {
+			unique := dedupTokens(chunkTokens)
+			result.DuplicatesRemoved += len(chunkTokens) - len(unique)
+			chunkTokens = unique
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			chunk := c.sendChunk(ctx, msgRequest, chunkTokens)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			result.Add(chunk)
+			if chunk.Err != nil && firstErr == nil {
+				firstErr = chunk.Err
+			}
+			if o.onIllegalTokens != nil && chunk.Result != nil && len(chunk.Result.IllegalTokens) > 0 {
+				o.onIllegalTokens(chunk.Result.IllegalTokens)
+			}
+			if onChunk != nil {
+				onChunk(chunk)
+			}
+		}()
+	}
+
+	var readErr, ctxErr error
+	buffer := make([]string, 0, o.chunkSize)
+read:
+	for {
+		select {
+		case <-ctx.Done():
+			ctxErr = ctx.Err()
+			break read
+		case token, ok := <-tokens:
+			if !ok {
+				if tokensErr != nil {
+					readErr = tokensErr()
+				}
+				break read
+			}
+
+			result.Tokens++
+			buffer = append(buffer, token)
+			if len(buffer) < o.chunkSize {
+				continue
+			}
+
+			if ctxErr = ctx.Err(); ctxErr != nil {
+				break read
+			}
+			dispatch(buffer)
+			buffer = make([]string, 0, o.chunkSize)
+		}
+	}
+
+	if len(buffer) > 0 {
+		if ctxErr == nil {
+			ctxErr = ctx.Err()
+		}
+		if ctxErr != nil {
+			result.Unsent = buffer
+		} else {
+			dispatch(buffer)
+		}
+	}
+	wg.Wait()
+
+	var errs []error
+	if readErr != nil {
+		errs = append(errs, fmt.Errorf("reading tokens failed after %d tokens: %w", result.Tokens, readErr))
+	}
+	if ctxErr != nil {
+		errs = append(errs, fmt.Errorf("stream stopped with %d tokens unsent: %w", len(result.Unsent), ctxErr))
+	}
+	if firstErr != nil {
+		errs = append(errs, chunksFailed(result.FailedChunks, result.Chunks, firstErr))
+	}
+	return result, errors.Join(errs...)
+}
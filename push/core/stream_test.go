@@ -0,0 +1,104 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/push/core"
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+// streamTokens sends the tokens on a channel closed after the last one
+func streamTokens(tokens ...string) <-chan string {
+	stream := make(chan string, len(tokens))
+	for _, token := range tokens {
+		stream <- token
+	}
+	close(stream)
+	return stream
+}
+
+func TestSendFromReaderChunks(t *testing.T) {
+	client, server := newTestClient(t)
+
+	var chunks []*model.ChunkResult
+	result, err := client.SendFromReader(context.Background(), tokenMessage(), streamTokens("a", "b", "c", "d", "e"), nil,
+		func(chunk *model.ChunkResult) { chunks = append(chunks, chunk) }, core.ChunkSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Tokens != 5 || result.Chunks != 3 || result.FailedChunks != 0 || len(result.Unsent) != 0 {
+		t.Errorf("result = %+v, want 5 tokens in 3 chunks", result)
+	}
+	if len(chunks) != 3 || len(server.SendRequests()) != 3 {
+		t.Errorf("chunks = %d, sends = %d, want 3", len(chunks), len(server.SendRequests()))
+	}
+
+	sent := 0
+	for _, r := range server.SendRequests() {
+		msgRequest, err := r.Message()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent += len(msgRequest.Message.Token)
+	}
+	if sent != 5 {
+		t.Errorf("tokens sent = %d, want the final partial chunk sent too", sent)
+	}
+}
+
+func TestSendFromReaderStreamError(t *testing.T) {
+	client, server := newTestClient(t)
+	streamErr := errors.New("cursor closed")
+
+	result, err := client.SendFromReader(context.Background(), tokenMessage(), streamTokens("a", "b", "c"),
+		func() error { return streamErr }, nil, core.ChunkSize(2))
+	if !errors.Is(err, streamErr) {
+		t.Fatalf("error = %v, want the stream error", err)
+	}
+	if result.Tokens != 3 || result.Chunks != 2 || len(server.SendRequests()) != 2 {
+		t.Errorf("result = %+v, want the tokens read before the error sent", result)
+	}
+}
+
+func TestSendFromReaderCancelledWhileWaitingForTokens(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	// the producer never closes the stream
+	tokens := make(chan string, 1)
+	tokens <- "a"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	done := make(chan struct{})
+	var result *model.StreamResult
+	var err error
+	go func() {
+		defer close(done)
+		result, err = client.SendFromReader(ctx, tokenMessage(), tokens, nil, nil, core.ChunkSize(2))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SendFromReader kept waiting for tokens after ctx was done")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if result.Tokens != 1 || len(result.Unsent) != 1 || result.Unsent[0] != "a" {
+		t.Errorf("result = %+v, want the buffered token unsent", result)
+	}
+}
+
+func TestSendFromReaderNilStream(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	if _, err := client.SendFromReader(context.Background(), tokenMessage(), nil, nil, nil); err == nil {
+		t.Error("a nil token stream was accepted")
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/dafanasiev/go-hms-push/httpclient"
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+// SubscribeTopic subscribes the tokens to the topic
+func (c *HMSClient) SubscribeTopic(ctx context.Context, topic string, tokens []string) (*model.TopicResponse, error) {
+	return c.topicOperation(ctx, subscribeURL, topic, tokens)
+}
+
+// UnsubscribeTopic unsubscribes the tokens from the topic
+func (c *HMSClient) UnsubscribeTopic(ctx context.Context, topic string, tokens []string) (*model.TopicResponse, error) {
+	return c.topicOperation(ctx, unsubscribeURL, topic, tokens)
+}
+
+// SubscribeTopics subscribes the tokens to each topic, with one request per topic as HMS takes a single topic
+// Up to concurrency requests run at once, less than 2 sends them one after the other
+// A failure on one topic doesn't stop the others, the failures are reported in the result
+func (c *HMSClient) SubscribeTopics(ctx context.Context, topics []string, tokens []string, concurrency int) (*model.SubscribeTopicsResult, error) {
+	if len(topics) == 0 {
+		return nil, errors.New("topics must not be empty")
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	result := &model.SubscribeTopicsResult{
+		Responses: make(map[string]*model.TopicResponse, len(topics)),
+		Failed:    map[string]error{},
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for _, topic := range topics {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(topic string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			resp, err := c.SubscribeTopic(ctx, topic, tokens)
+			if err == nil && resp.Code != constant.CodeSuccess {
+				err = fmt.Errorf("code %s: %s", resp.Code, resp.Msg)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if resp != nil {
+				result.Responses[topic] = resp
+			}
+			if err != nil {
+				result.Failed[topic] = err
+			}
+		}(topic)
+	}
+	wg.Wait()
+
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("failed to subscribe to %d of %d topics", len(result.Failed), len(topics))
+	}
+	return result, nil
+}
+
+// ListTopics lists the topics the token is subscribed to
+func (c *HMSClient) ListTopics(ctx context.Context, token string) (*model.TopicListResponse, error) {
+	ctx, cancel := c.withDefaultDeadline(ctx)
+	defer cancel()
+
+	if token == "" {
+		return nil, errors.New("token can't be empty")
+	}
+
+	urls, _, err := c.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.TopicListResponse{}
+	request, err := c.newApiRequest(ctx, urls.listTopics, &model.TopicListRequest{Token: token})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = c.executeApiOperation(ctx, request, result); err != nil {
+		return result, err
+	}
+	if result.Code != constant.CodeSuccess {
+		return result, fmt.Errorf("failed to list topics: code %s: %s", result.Code, result.Msg)
+	}
+	return result, nil
+}
+
+// UnsubscribeAll unsubscribes the token from every topic it is subscribed to
+// A failure on one topic doesn't stop the others, the failures are reported in the result
+func (c *HMSClient) UnsubscribeAll(ctx context.Context, token string) (*model.UnsubscribeAllResult, error) {
+	list, err := c.ListTopics(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.UnsubscribeAllResult{Failed: map[string]error{}}
+	for _, topic := range list.Topics {
+		resp, err := c.UnsubscribeTopic(ctx, topic.Name, []string{token})
+		if err == nil && resp.Code != constant.CodeSuccess {
+			err = fmt.Errorf("code %s: %s", resp.Code, resp.Msg)
+		}
+
+		if err != nil {
+			result.Failed[topic.Name] = err
+			continue
+		}
+		result.Unsubscribed = append(result.Unsubscribed, topic.Name)
+	}
+
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("failed to unsubscribe from %d of %d topics", len(result.Failed), len(list.Topics))
+	}
+	return result, nil
+}
+
+func subscribeURL(urls *clientURLs) string   { return urls.subscribe }
+func unsubscribeURL(urls *clientURLs) string { return urls.unsubscribe }
+
+func (c *HMSClient) topicOperation(ctx context.Context, url func(*clientURLs) string, topic string, tokens []string) (*model.TopicResponse, error) {
+	ctx, cancel := c.withDefaultDeadline(ctx)
+	defer cancel()
+
+	if topic == "" {
+		return nil, errors.New("topic can't be empty")
+	}
+
+	if len(tokens) == 0 {
+		return nil, errors.New("token must not be empty")
+	}
+
+	urls, _, err := c.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.TopicResponse{}
+	request, err := c.newApiRequest(ctx, url(urls), &model.TopicRequest{Topic: topic, TokenArray: tokens})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.executeApiOperation(ctx, request, result)
+	return result, err
+}
+
+func (c *HMSClient) newApiRequest(ctx context.Context, url string, body interface{}) (*httpclient.PushRequest, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &httpclient.PushRequest{
+		Method: http.MethodPost,
+		URL:    url,
+		Body:   b,
+	}
+	if err = c.resetHTTPHeader(ctx, request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
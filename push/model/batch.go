@@ -0,0 +1,133 @@
+package model
+
+import "time"
+
+// SendPlan previews the requests a batch send would make
+type SendPlan struct {
+	// Tokens is the number of tokens left after de-duplication
+	Tokens    int
+	ChunkSize int
+	Chunks    int
+	// RequestsPerSecond is the configured rate limit, zero when unlimited
+	RequestsPerSecond float64
+	// EstimatedDuration is the time the rate limit alone imposes, zero when unlimited
+	// It doesn't account for request latency or retries
+	EstimatedDuration time.Duration
+}
+
+// BatchResult aggregates the results of a send to many tokens, split in chunks
+type BatchResult struct {
+	// Chunks holds the outcome of each chunk in sending order
+	Chunks       []*ChunkResult
+	SuccessCount int
+	FailureCount int
+	// IllegalTokens holds the illegal tokens of every chunk once, in the order they were first reported,
+	// up to the limit of NewBatchResult
+	IllegalTokens []string
+	// IllegalTokenCount counts the illegal tokens, those beyond the limit included
+	IllegalTokenCount int
+	// IllegalTokensTruncated is set once an illegal token was left out for the limit
+	IllegalTokensTruncated bool
+	// DuplicatesRemoved is the number of duplicate tokens dropped before chunking
+	DuplicatesRemoved int
+	// Unsent holds the tokens of the chunks left unsent because the context was done,
+	// the tokens of Chunks were all attempted
+	Unsent []string
+
+	illegal map[string]struct{}
+	// zero means no limit
+	maxIllegal int
+	onIllegal  func(tokens []string)
+}
+
+// NewBatchResult returns an empty result keeping at most maxIllegalTokens illegal tokens, zero means all
+// Beyond the limit the illegal tokens are only counted, duplicates are then only detected among the kept tokens
+// The limit only applies to IllegalTokens, the chunk results keep the illegal tokens HMS reported
+// onIllegalTokens, if not nil, receives the illegal tokens of every chunk, limit or not
+func NewBatchResult(maxIllegalTokens int, onIllegalTokens func(tokens []string)) *BatchResult {
+	return &BatchResult{maxIllegal: maxIllegalTokens, onIllegal: onIllegalTokens}
+}
+
+// StreamResult totals a send to a stream of tokens, which unlike BatchResult keeps no token
+// to stay in constant memory, the chunks are handed to a callback instead
+type StreamResult struct {
+	// Tokens is the number of tokens read from the stream, Chunks and FailedChunks the requests sent and failed
+	Tokens       int
+	Chunks       int
+	FailedChunks int
+	SuccessCount int
+	FailureCount int
+	// IllegalTokenCount counts the illegal tokens reported, a token repeated across chunks once per chunk
+	IllegalTokenCount int
+	// DuplicatesRemoved is the number of duplicate tokens dropped, duplicates are only detected within a chunk
+	DuplicatesRemoved int
+	// Unsent holds the tokens read but left unsent because the context was done, at most a chunk of them
+	Unsent []string
+}
+
+// Add updates the totals with the outcome of a chunk
+func (r *StreamResult) Add(chunk *ChunkResult) {
+	r.Chunks++
+	if chunk.Err != nil {
+		r.FailedChunks++
+	}
+	if chunk.Result == nil {
+		return
+	}
+
+	r.SuccessCount += chunk.Result.SuccessCount
+	r.FailureCount += chunk.Result.FailureCount
+	r.IllegalTokenCount += len(chunk.Result.IllegalTokens)
+}
+
+// ChunkResult is the outcome of one chunk of a batch send
+type ChunkResult struct {
+	Tokens []string
+	// Result is nil when the chunk failed without a response
+	Result *SendResult
+	Err    error
+}
+
+// Add appends the outcome of a chunk and updates the totals
+func (r *BatchResult) Add(chunk *ChunkResult) {
+	r.Chunks = append(r.Chunks, chunk)
+	if chunk.Result == nil {
+		return
+	}
+
+	r.SuccessCount += chunk.Result.SuccessCount
+	r.FailureCount += chunk.Result.FailureCount
+	if r.onIllegal != nil && len(chunk.Result.IllegalTokens) > 0 {
+		r.onIllegal(chunk.Result.IllegalTokens)
+	}
+
+	for _, token := range chunk.Result.IllegalTokens {
+		if _, ok := r.illegal[token]; ok {
+			continue
+		}
+		r.IllegalTokenCount++
+		if r.maxIllegal > 0 && len(r.IllegalTokens) >= r.maxIllegal {
+			r.IllegalTokensTruncated = true
+			continue
+		}
+		if r.illegal == nil {
+			r.illegal = map[string]struct{}{}
+		}
+		r.illegal[token] = struct{}{}
+		r.IllegalTokens = append(r.IllegalTokens, token)
+	}
+}
+
+// Retryable returns the tokens of the batch worth sending again: the Retryable tokens of each chunk,
+// every token of a chunk that failed without a response, then the Unsent tokens
+func (r *BatchResult) Retryable() []string {
+	var retryable []string
+	for _, chunk := range r.Chunks {
+		if chunk.Result == nil {
+			retryable = append(retryable, chunk.Tokens...)
+			continue
+		}
+		retryable = append(retryable, chunk.Result.Retryable()...)
+	}
+	return append(retryable, r.Unsent...)
+}
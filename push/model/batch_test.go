@@ -0,0 +1,57 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+)
+
+func illegalChunk(tokens []string, illegal ...string) *ChunkResult {
+	return &ChunkResult{Tokens: tokens, Result: &SendResult{Code: constant.CodePartialSuccess,
+		SuccessCount: len(tokens) - len(illegal), FailureCount: len(illegal), IllegalTokens: illegal}}
+}
+
+func TestBatchResultDeduplicatesIllegalTokensAcrossChunks(t *testing.T) {
+	var streamed [][]string
+	result := NewBatchResult(0, func(tokens []string) { streamed = append(streamed, tokens) })
+
+	// the same token may be reported by several chunks, e.g. when deduplication is off
+	result.Add(illegalChunk([]string{"a", "b", "c"}, "a", "b"))
+	result.Add(illegalChunk([]string{"b", "c", "d"}, "b", "d"))
+	result.Add(illegalChunk([]string{"a", "d"}, "a", "d"))
+
+	if want := []string{"a", "b", "d"}; !reflect.DeepEqual(result.IllegalTokens, want) {
+		t.Errorf("illegal tokens = %v, want each once in reporting order %v", result.IllegalTokens, want)
+	}
+	if result.IllegalTokenCount != 3 || result.IllegalTokensTruncated {
+		t.Errorf("illegal token count = %d, truncated %t, want 3 and not truncated",
+			result.IllegalTokenCount, result.IllegalTokensTruncated)
+	}
+	if result.SuccessCount != 2 || result.FailureCount != 6 {
+		t.Errorf("success = %d, failure = %d, want the chunk totals 2 and 6", result.SuccessCount, result.FailureCount)
+	}
+	if want := [][]string{{"a", "b"}, {"b", "d"}, {"a", "d"}}; !reflect.DeepEqual(streamed, want) {
+		t.Errorf("streamed = %v, want the illegal tokens of every chunk %v", streamed, want)
+	}
+}
+
+func TestBatchResultIllegalTokenLimitLeavesChunksAlone(t *testing.T) {
+	result := NewBatchResult(2, nil)
+	first := illegalChunk([]string{"a", "b", "c"}, "a", "b")
+	second := illegalChunk([]string{"d", "e"}, "d", "e")
+	result.Add(first)
+	result.Add(second)
+
+	if want := []string{"a", "b"}; !reflect.DeepEqual(result.IllegalTokens, want) {
+		t.Errorf("illegal tokens = %v, want the first %v", result.IllegalTokens, want)
+	}
+	if result.IllegalTokenCount != 4 || !result.IllegalTokensTruncated {
+		t.Errorf("illegal token count = %d, truncated %t, want 4 and truncated",
+			result.IllegalTokenCount, result.IllegalTokensTruncated)
+	}
+	if !reflect.DeepEqual(first.Result.IllegalTokens, []string{"a", "b"}) ||
+		!reflect.DeepEqual(second.Result.IllegalTokens, []string{"d", "e"}) {
+		t.Errorf("chunk illegal tokens = %v and %v, want them untouched", first.Result.IllegalTokens, second.Result.IllegalTokens)
+	}
+}
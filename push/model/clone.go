@@ -0,0 +1,219 @@
+package model
+
+// Clone returns a deep copy of the request
+func (r *MessageRequest) Clone() *MessageRequest {
+	if r == nil {
+		return nil
+	}
+
+	c := *r
+	c.Message = r.Message.Clone()
+	return &c
+}
+
+// Clone returns a deep copy of the message, so a template can be reused for
+// many sends without the copies sharing slices, maps or nested structs
+// Values of Apns.Payload and AndroidNotification.MultiLangKey are copied deeply when they are
+// maps, slices or the Aps and AlertDictionary types of this package, other values are copied as is
+func (m *Message) Clone() *Message {
+	if m == nil {
+		return nil
+	}
+
+	c := *m
+	c.Notification = m.Notification.Clone()
+	c.Android = m.Android.Clone()
+	c.Apns = m.Apns.Clone()
+	c.WebPush = m.WebPush.Clone()
+	c.Token = cloneStrings(m.Token)
+	c.Extra = m.Extra.clone()
+	return &c
+}
+
+// Clone returns a copy of the notification
+func (n *Notification) Clone() *Notification {
+	if n == nil {
+		return nil
+	}
+
+	c := *n
+	return &c
+}
+
+// Clone returns a deep copy of the android config
+func (a *AndroidConfig) Clone() *AndroidConfig {
+	if a == nil {
+		return nil
+	}
+
+	c := *a
+	if a.AnalyticInfo != nil {
+		c.AnalyticInfo = make(AnalyticInfo, len(a.AnalyticInfo))
+		for k, v := range a.AnalyticInfo {
+			c.AnalyticInfo[k] = v
+		}
+	}
+	c.Notification = a.Notification.Clone()
+	c.Extra = a.Extra.clone()
+	return &c
+}
+
+// Clone returns a deep copy of the android notification
+func (n *AndroidNotification) Clone() *AndroidNotification {
+	if n == nil {
+		return nil
+	}
+
+	c := *n
+	if n.ClickAction != nil {
+		clickAction := *n.ClickAction
+		c.ClickAction = &clickAction
+	}
+	if n.Badge != nil {
+		badge := *n.Badge
+		c.Badge = &badge
+	}
+	c.AutoClear = cloneInt(n.AutoClear)
+	c.NotifyId = cloneInt(n.NotifyId)
+	c.AutoCancel = cloneBool(n.AutoCancel)
+	c.ForegroundShow = cloneBool(n.ForegroundShow)
+	if n.LightSettings != nil {
+		lightSettings := *n.LightSettings
+		if n.LightSettings.Color != nil {
+			color := *n.LightSettings.Color
+			lightSettings.Color = &color
+		}
+		c.LightSettings = &lightSettings
+	}
+	c.BodyLocArgs = cloneStrings(n.BodyLocArgs)
+	c.TitleLocArgs = cloneStrings(n.TitleLocArgs)
+	c.VibrateConfig = cloneStrings(n.VibrateConfig)
+	c.MultiLangKey = cloneMap(n.MultiLangKey)
+	c.Extra = n.Extra.clone()
+	return &c
+}
+
+// Clone returns a deep copy of the apns config
+func (a *Apns) Clone() *Apns {
+	if a == nil {
+		return nil
+	}
+
+	c := *a
+	if a.Headers != nil {
+		headers := *a.Headers
+		if a.Headers.Custom != nil {
+			headers.Custom = make(map[string]string, len(a.Headers.Custom))
+			for k, v := range a.Headers.Custom {
+				headers.Custom[k] = v
+			}
+		}
+		c.Headers = &headers
+	}
+	if a.HmsOptions != nil {
+		hmsOptions := *a.HmsOptions
+		c.HmsOptions = &hmsOptions
+	}
+	c.Payload = cloneMap(a.Payload)
+	return &c
+}
+
+// Clone returns a deep copy of the web push config
+func (w *WebPushConfig) Clone() *WebPushConfig {
+	if w == nil {
+		return nil
+	}
+
+	c := *w
+	if w.Headers != nil {
+		headers := *w.Headers
+		c.Headers = &headers
+	}
+	if w.HmsOptions != nil {
+		hmsOptions := *w.HmsOptions
+		c.HmsOptions = &hmsOptions
+	}
+	if w.Notification != nil {
+		notification := *w.Notification
+		if w.Notification.Actions != nil {
+			notification.Actions = make([]*WebPushAction, len(w.Notification.Actions))
+			for i, action := range w.Notification.Actions {
+				if action != nil {
+					a := *action
+					notification.Actions[i] = &a
+				}
+			}
+		}
+		if w.Notification.Vibrate != nil {
+			notification.Vibrate = append([]int(nil), w.Notification.Vibrate...)
+		}
+		c.Notification = &notification
+	}
+	c.Extra = w.Extra.clone()
+	return &c
+}
+
+func cloneInt(i *int) *int {
+	if i == nil {
+		return nil
+	}
+	return Int(*i)
+}
+
+func cloneBool(b *bool) *bool {
+	if b == nil {
+		return nil
+	}
+	return Bool(*b)
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+func cloneMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = cloneValue(v)
+	}
+	return c
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return cloneMap(value)
+	case []interface{}:
+		c := make([]interface{}, len(value))
+		for i, item := range value {
+			c[i] = cloneValue(item)
+		}
+		return c
+	case []string:
+		return cloneStrings(value)
+	case *Aps:
+		if value == nil {
+			return value
+		}
+		aps := *value
+		aps.Alert = cloneValue(value.Alert)
+		return &aps
+	case *AlertDictionary:
+		if value == nil {
+			return value
+		}
+		alert := *value
+		alert.TitleLocArgs = cloneStrings(value.TitleLocArgs)
+		alert.LocArgs = cloneStrings(value.LocArgs)
+		return &alert
+	default:
+		return v
+	}
+}
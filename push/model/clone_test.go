@@ -0,0 +1,147 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// fullMessageRequest sets every slice, map and pointer field Clone copies
+func fullMessageRequest() *MessageRequest {
+	return &MessageRequest{
+		Message: &Message{
+			Data:         "data",
+			Notification: &Notification{Title: "title", Body: "body"},
+			Android: &AndroidConfig{
+				BiTag:        "campaign",
+				AnalyticInfo: AnalyticInfo{"utm_source": "mail"},
+				Notification: &AndroidNotification{
+					Title:          "title",
+					ClickAction:    &ClickAction{Type: 1, Action: "open"},
+					Badge:          &BadgeNotification{AddNum: 1},
+					AutoClear:      Int(1000),
+					NotifyId:       Int(7),
+					AutoCancel:     Bool(true),
+					ForegroundShow: Bool(false),
+					LightSettings:  &LightSettings{Color: &Color{Alpha: 1, Red: 1}, LightOnDuration: "1s"},
+					BodyLocArgs:    []string{"body arg"},
+					TitleLocArgs:   []string{"title arg"},
+					VibrateConfig:  []string{"1s"},
+					MultiLangKey:   map[string]interface{}{"title_key": map[string]interface{}{"en": "title"}},
+					Extra:          Extra{"notification_extra": json.RawMessage(`1`)},
+				},
+				Extra: Extra{"android_extra": json.RawMessage(`"a"`)},
+			},
+			Apns: &Apns{
+				Headers:    &ApnsHeaders{ApnsId: "id", Custom: map[string]string{"apns-custom": "value"}},
+				HmsOptions: &ApnsHmsOptions{TargetUserType: 1},
+				Payload: map[string]interface{}{
+					"aps": &Aps{Alert: &AlertDictionary{
+						Title:        "title",
+						TitleLocArgs: []string{"title arg"},
+						LocArgs:      []string{"loc arg"},
+					}},
+					"list":    []interface{}{"a", map[string]interface{}{"b": "c"}},
+					"strings": []string{"s"},
+				},
+			},
+			WebPush: &WebPushConfig{
+				Headers:    &WebPushHeaders{TTL: "990"},
+				HmsOptions: &HmsWebPushOption{Link: "https://example.com"},
+				Notification: &WebPushNotification{
+					Title:   "title",
+					Actions: []*WebPushAction{{Action: "open"}},
+					Vibrate: []int{100},
+				},
+				Extra: Extra{"webpush_extra": json.RawMessage(`true`)},
+			},
+			Token: []string{"token"},
+			Extra: Extra{"message_extra": json.RawMessage(`{}`)},
+		},
+	}
+}
+
+func TestMessageRequestCloneIsDeep(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(c *MessageRequest)
+	}{
+		{"validate only", func(c *MessageRequest) { c.ValidateOnly = true }},
+		{"message", func(c *MessageRequest) { c.Message.Data = "changed" }},
+		{"token", func(c *MessageRequest) { c.Message.Token[0] = "changed" }},
+		{"message extra", func(c *MessageRequest) { c.Message.Extra["message_extra"][0] = '[' }},
+		{"message extra key", func(c *MessageRequest) { c.Message.Extra["added"] = json.RawMessage(`1`) }},
+		{"notification", func(c *MessageRequest) { c.Message.Notification.Title = "changed" }},
+
+		{"android", func(c *MessageRequest) { c.Message.Android.BiTag = "changed" }},
+		{"android analytic info", func(c *MessageRequest) { c.Message.Android.AnalyticInfo["utm_source"] = "changed" }},
+		{"android extra", func(c *MessageRequest) { c.Message.Android.Extra["android_extra"][1] = 'b' }},
+		{"android notification", func(c *MessageRequest) { c.Message.Android.Notification.Title = "changed" }},
+		{"click action", func(c *MessageRequest) { c.Message.Android.Notification.ClickAction.Action = "changed" }},
+		{"badge", func(c *MessageRequest) { c.Message.Android.Notification.Badge.AddNum = 2 }},
+		{"auto clear", func(c *MessageRequest) { *c.Message.Android.Notification.AutoClear = 0 }},
+		{"notify id", func(c *MessageRequest) { *c.Message.Android.Notification.NotifyId = 0 }},
+		{"auto cancel", func(c *MessageRequest) { *c.Message.Android.Notification.AutoCancel = false }},
+		{"foreground show", func(c *MessageRequest) { *c.Message.Android.Notification.ForegroundShow = true }},
+		{"light settings", func(c *MessageRequest) { c.Message.Android.Notification.LightSettings.LightOnDuration = "2s" }},
+		{"light color", func(c *MessageRequest) { c.Message.Android.Notification.LightSettings.Color.Red = 0 }},
+		{"body loc args", func(c *MessageRequest) { c.Message.Android.Notification.BodyLocArgs[0] = "changed" }},
+		{"title loc args", func(c *MessageRequest) { c.Message.Android.Notification.TitleLocArgs[0] = "changed" }},
+		{"vibrate config", func(c *MessageRequest) { c.Message.Android.Notification.VibrateConfig[0] = "2s" }},
+		{"multi lang key", func(c *MessageRequest) {
+			c.Message.Android.Notification.MultiLangKey["title_key"].(map[string]interface{})["en"] = "changed"
+		}},
+		{"notification extra", func(c *MessageRequest) { c.Message.Android.Notification.Extra["notification_extra"][0] = '2' }},
+
+		{"apns headers", func(c *MessageRequest) { c.Message.Apns.Headers.ApnsId = "changed" }},
+		{"apns custom headers", func(c *MessageRequest) { c.Message.Apns.Headers.Custom["apns-custom"] = "changed" }},
+		{"apns hms options", func(c *MessageRequest) { c.Message.Apns.HmsOptions.TargetUserType = 2 }},
+		{"apns payload", func(c *MessageRequest) { c.Message.Apns.Payload["added"] = "value" }},
+		{"aps", func(c *MessageRequest) { c.Message.Apns.Payload["aps"].(*Aps).Badge = 1 }},
+		{"aps alert", func(c *MessageRequest) {
+			c.Message.Apns.Payload["aps"].(*Aps).Alert.(*AlertDictionary).Title = "changed"
+		}},
+		{"aps alert title loc args", func(c *MessageRequest) {
+			c.Message.Apns.Payload["aps"].(*Aps).Alert.(*AlertDictionary).TitleLocArgs[0] = "changed"
+		}},
+		{"aps alert loc args", func(c *MessageRequest) {
+			c.Message.Apns.Payload["aps"].(*Aps).Alert.(*AlertDictionary).LocArgs[0] = "changed"
+		}},
+		{"payload list", func(c *MessageRequest) { c.Message.Apns.Payload["list"].([]interface{})[0] = "changed" }},
+		{"payload nested map", func(c *MessageRequest) {
+			c.Message.Apns.Payload["list"].([]interface{})[1].(map[string]interface{})["b"] = "changed"
+		}},
+		{"payload strings", func(c *MessageRequest) { c.Message.Apns.Payload["strings"].([]string)[0] = "changed" }},
+
+		{"webpush headers", func(c *MessageRequest) { c.Message.WebPush.Headers.TTL = "1" }},
+		{"webpush hms options", func(c *MessageRequest) { c.Message.WebPush.HmsOptions.Link = "changed" }},
+		{"webpush notification", func(c *MessageRequest) { c.Message.WebPush.Notification.Title = "changed" }},
+		{"webpush actions", func(c *MessageRequest) { c.Message.WebPush.Notification.Actions[0].Action = "changed" }},
+		{"webpush vibrate", func(c *MessageRequest) { c.Message.WebPush.Notification.Vibrate[0] = 0 }},
+		{"webpush extra", func(c *MessageRequest) { c.Message.WebPush.Extra["webpush_extra"][0] = 'f' }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := fullMessageRequest()
+			clone := original.Clone()
+			if !reflect.DeepEqual(original, clone) {
+				t.Fatal("clone differs from the original before any change")
+			}
+
+			tt.mutate(clone)
+			if !reflect.DeepEqual(original, fullMessageRequest()) {
+				t.Errorf("changing the clone changed the original")
+			}
+		})
+	}
+}
+
+func TestNilClones(t *testing.T) {
+	if (*MessageRequest)(nil).Clone() != nil || (*Message)(nil).Clone() != nil {
+		t.Error("clone of nil isn't nil")
+	}
+	if c := (&MessageRequest{}).Clone(); c == nil || c.Message != nil {
+		t.Errorf("clone of an empty request = %+v", c)
+	}
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	conditionAnd = "&&"
+	conditionOr  = "||"
+)
+
+// topicPattern is the topic name format HMS accepts, it leaves no room for a quote breaking the expression
+var topicPattern = regexp.MustCompile(`^[a-zA-Z0-9_.~%-]{1,900}$`)
+
+// ConditionBuilder builds a topic condition expression for Message.Condition
+// Operators apply left to right: Condition().InTopic("A").Or().InTopic("B").And().InTopic("C")
+// produces "('A' in topics || 'B' in topics) && 'C' in topics"
+type ConditionBuilder struct {
+	expr string
+	// operator joining the top level operands of expr, empty for a single operand
+	op string
+	// operator set by And or Or for the next operand
+	next string
+	// first invalid topic added, see Build
+	err error
+}
+
+// Condition returns an empty condition builder
+func Condition() *ConditionBuilder {
+	return &ConditionBuilder{}
+}
+
+// InTopic adds an operand matching devices subscribed to the topic
+// The topic must match [a-zA-Z0-9-_.~%]{1,900}, Build reports it otherwise
+func (b *ConditionBuilder) InTopic(topic string) *ConditionBuilder {
+	b.checkTopic(topic)
+	b.add(fmt.Sprintf("'%s' in topics", topic))
+	return b
+}
+
+// NotInTopic adds an operand matching devices not subscribed to the topic
+// The topic must match [a-zA-Z0-9-_.~%]{1,900}, Build reports it otherwise
+func (b *ConditionBuilder) NotInTopic(topic string) *ConditionBuilder {
+	b.checkTopic(topic)
+	b.add(fmt.Sprintf("!('%s' in topics)", topic))
+	return b
+}
+
+// And joins the given conditions, or the next operand when called without arguments, with &&
+func (b *ConditionBuilder) And(conditions ...*ConditionBuilder) *ConditionBuilder {
+	return b.join(conditionAnd, conditions)
+}
+
+// Or joins the given conditions, or the next operand when called without arguments, with ||
+func (b *ConditionBuilder) Or(conditions ...*ConditionBuilder) *ConditionBuilder {
+	return b.join(conditionOr, conditions)
+}
+
+// String returns the condition expression as expected by HMS, without checking the topics
+// Use Build unless the topics are known to be valid, e.g. constants
+func (b *ConditionBuilder) String() string {
+	return b.expr
+}
+
+// Build returns the condition expression, or an error for the first topic HMS would reject,
+// including those of the joined conditions
+func (b *ConditionBuilder) Build() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	return b.expr, nil
+}
+
+func (b *ConditionBuilder) checkTopic(topic string) {
+	if b.err == nil && !topicPattern.MatchString(topic) {
+		b.err = fmt.Errorf("invalid topic %q, it must match %s", topic, topicPattern)
+	}
+}
+
+func (b *ConditionBuilder) join(op string, conditions []*ConditionBuilder) *ConditionBuilder {
+	if len(conditions) == 0 {
+		b.next = op
+		return b
+	}
+
+	for _, condition := range conditions {
+		if condition == nil {
+			continue
+		}
+		if b.err == nil {
+			b.err = condition.err
+		}
+		if condition.expr == "" {
+			continue
+		}
+		b.next = op
+		b.add(condition.operand())
+	}
+	return b
+}
+
+func (b *ConditionBuilder) operand() string {
+	if b.op == "" {
+		return b.expr
+	}
+	return "(" + b.expr + ")"
+}
+
+func (b *ConditionBuilder) add(operand string) {
+	op := b.next
+	b.next = ""
+
+	if b.expr == "" {
+		b.expr = operand
+		return
+	}
+
+	if op == "" {
+		op = conditionAnd
+	}
+
+	// && binds tighter than || in HMS, so keep left to right order explicit
+	if b.op != "" && b.op != op {
+		b.expr = "(" + b.expr + ")"
+	}
+
+	b.expr = b.expr + " " + op + " " + operand
+	b.op = op
+}
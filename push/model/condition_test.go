@@ -0,0 +1,65 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConditionBuilder(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition *ConditionBuilder
+		want      string
+	}{
+		{"single topic", Condition().InTopic("A"), "'A' in topics"},
+		{"not in topic", Condition().NotInTopic("A"), "!('A' in topics)"},
+		{"implicit and", Condition().InTopic("A").InTopic("B"), "'A' in topics && 'B' in topics"},
+		{"left to right", Condition().InTopic("A").Or().InTopic("B").And().InTopic("C"),
+			"('A' in topics || 'B' in topics) && 'C' in topics"},
+		{"nested or", Condition().InTopic("A").And(Condition().InTopic("B").Or().InTopic("C")),
+			"'A' in topics && ('B' in topics || 'C' in topics)"},
+		{"nested and", Condition().InTopic("A").Or(Condition().InTopic("B").And().NotInTopic("C")),
+			"'A' in topics || ('B' in topics && !('C' in topics))"},
+		{"same operator", Condition().InTopic("A").Or(Condition().InTopic("B")).Or().InTopic("C"),
+			"'A' in topics || 'B' in topics || 'C' in topics"},
+		{"nil and empty joins", Condition().InTopic("A").And(nil, Condition()), "'A' in topics"},
+		{"topic characters", Condition().InTopic("news_2026-10.~%20"), "'news_2026-10.~%20' in topics"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.condition.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || tt.condition.String() != tt.want {
+				t.Errorf("condition = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionBuilderInvalidTopics(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition *ConditionBuilder
+	}{
+		{"quote", Condition().InTopic("A' in topics || 'B")},
+		{"space", Condition().NotInTopic("breaking news")},
+		{"empty", Condition().InTopic("")},
+		{"too long", Condition().InTopic(strings.Repeat("a", 901))},
+		{"in a joined condition", Condition().InTopic("A").And(Condition().InTopic("B").Or().InTopic("C'"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.condition.Build(); err == nil {
+				t.Errorf("condition %q was built", got)
+			}
+		})
+	}
+
+	if _, err := Condition().InTopic(strings.Repeat("a", 900)).Build(); err != nil {
+		t.Errorf("900 characters topic: %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+import "github.com/dafanasiev/go-hms-push/push/constant"
+
+// MessageDefaults are android config fields applied to every message a client sends
+// A field the message sets itself wins over the default, empty defaults are ignored
+type MessageDefaults struct {
+	CollapseKey int
+	Urgency     string
+	Category    constant.Category
+	TTL         string
+	BiTag       string
+}
+
+// Apply fills the android config fields message leaves empty with the defaults,
+// creating the android config when needed
+// Web push messages are left alone, their android config would be ignored
+func (d *MessageDefaults) Apply(message *Message) {
+	if d == nil || message == nil || message.WebPush != nil {
+		return
+	}
+
+	if message.Android == nil {
+		message.Android = &AndroidConfig{}
+	}
+	android := message.Android
+
+	if android.CollapseKey == 0 {
+		android.CollapseKey = d.CollapseKey
+	}
+	if android.Urgency == "" {
+		android.Urgency = d.Urgency
+	}
+	if android.Category == "" {
+		android.Category = string(d.Category)
+	}
+	if android.TTL == "" {
+		android.TTL = d.TTL
+	}
+	if android.BiTag == "" {
+		android.BiTag = d.BiTag
+	}
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Extra holds message fields HMS supports but this package doesn't model yet
+// The fields are merged into the JSON object of the struct carrying them, so they're sent at that nesting level
+// A key naming a modeled field of the struct fails the marshaling
+type Extra map[string]json.RawMessage
+
+// Set adds the field marshaled from value
+func (e *Extra) Set(key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("extra field %q: %w", key, err)
+	}
+
+	if *e == nil {
+		*e = Extra{}
+	}
+	(*e)[key] = raw
+	return nil
+}
+
+func (e Extra) clone() Extra {
+	if e == nil {
+		return nil
+	}
+
+	c := make(Extra, len(e))
+	for k, v := range e {
+		c[k] = append(json.RawMessage(nil), v...)
+	}
+	return c
+}
+
+// the types below drop the MarshalJSON methods so the modeled fields marshal as usual
+type (
+	message             Message
+	androidConfig       AndroidConfig
+	androidNotification AndroidNotification
+	webPushConfig       WebPushConfig
+)
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(message(m), m.Extra)
+}
+
+func (a AndroidConfig) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(androidConfig(a), a.Extra)
+}
+
+func (n AndroidNotification) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(androidNotification(n), n.Extra)
+}
+
+func (w WebPushConfig) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(webPushConfig(w), w.Extra)
+}
+
+func marshalWithExtra(v interface{}, extra Extra) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return body, err
+	}
+
+	modeled := jsonFieldNames(reflect.TypeOf(v))
+	fields := make(map[string]json.RawMessage, len(extra))
+	if err = json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+
+	for key, value := range extra {
+		if modeled[key] {
+			return nil, fmt.Errorf("extra field %q collides with a modeled field", key)
+		}
+		fields[key] = value
+	}
+	return json.Marshal(fields)
+}
+
+// jsonFieldNames returns the JSON names of the fields of struct type t, set or not
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
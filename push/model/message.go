@@ -17,6 +17,8 @@ Copyright 2020. Huawei Technologies Co., Ltd. All rights reserved.
 package model
 
 import (
+	"encoding/json"
+
 	"github.com/dafanasiev/go-hms-push/push/constant"
 )
 
@@ -25,6 +27,14 @@ type MessageRequest struct {
 	Message      *Message `json:"message"`
 }
 
+// RawMessageRequest is a MessageRequest whose message was serialized elsewhere
+type RawMessageRequest struct {
+	ValidateOnly bool            `json:"validate_only"`
+	Message      json.RawMessage `json:"message"`
+	// SkipValidation sends Message without checking its structure first
+	SkipValidation bool `json:"-"`
+}
+
 type MessageResponse struct {
 	Code      string `json:"code"`
 	Msg       string `json:"msg"`
@@ -40,6 +50,7 @@ type Message struct {
 	Token        []string       `json:"token,omitempty"`
 	Topic        string         `json:"topic,omitempty"`
 	Condition    string         `json:"condition,omitempty"`
+	Extra        Extra          `json:"-"`
 }
 
 type Notification struct {
@@ -65,6 +76,11 @@ type ApnsHeaders struct {
 	ApnsPriority   string `json:"apns-priority,omitempty"`
 	ApnsTopic      string `json:"apns-topic,omitempty"`
 	ApnsCollapseId string `json:"apns-collapse-id,omitempty"`
+	// ApnsPushType is one of the constant.ApnsPushType values, the payload and topic must match it
+	ApnsPushType string `json:"apns-push-type,omitempty"`
+	// Custom holds the apns headers without a field, set them with Set
+	// All headers are serialized as a string map
+	Custom map[string]string `json:"-"`
 }
 
 type Aps struct {
@@ -72,12 +88,14 @@ type Aps struct {
 	Badge            int         `json:"badge,omitempty"`
 	Sound            string      `json:"sound,omitempty"`
 	ContentAvailable int         `json:"content-available,omitempty"`
+	MutableContent   int         `json:"mutable-content,omitempty"`
 	Category         string      `json:"category,omitempty"`
 	ThreadId         string      `json:"thread-id,omitempty"`
 }
 
 type AlertDictionary struct {
 	Title        string   `json:"title,omitempty"`
+	Subtitle     string   `json:"subtitle,omitempty"`
 	Body         string   `json:"body,omitempty"`
 	TitleLocKey  string   `json:"title-loc-key,omitempty"`
 	TitleLocArgs []string `json:"title-loc-args,omitempty"`
@@ -87,6 +105,106 @@ type AlertDictionary struct {
 	LaunchImage  string   `json:"launch-image,omitempty"`
 }
 
+// WithData sets the custom data payload of the message
+func (m *Message) WithData(data string) *Message {
+	m.Data = data
+	return m
+}
+
+// WithDataMap sets the custom data payload of the message to the JSON object of data,
+// which the app reads back as a map
+func (m *Message) WithDataMap(data map[string]string) *Message {
+	// a map of strings always marshals
+	raw, _ := json.Marshal(data)
+	m.Data = string(raw)
+	return m
+}
+
+// WithNotification sets the notification displayed for the message
+// It can be combined with WithData, see Validate for how the two interact
+func (m *Message) WithNotification(notification *Notification) *Message {
+	m.Notification = notification
+	return m
+}
+
+// Validate returns warnings about field combinations which are valid but likely to behave unexpectedly
+// Invalid messages are rejected by verify.ValidateMessage when sending
+func (m *Message) Validate() []string {
+	var warnings []string
+
+	hasNotification := m.Notification != nil || (m.Android != nil && m.Android.Notification != nil)
+	hasData := m.Data != "" || (m.Android != nil && m.Android.Data != "")
+
+	if hasNotification && hasData {
+		warnings = append(warnings, "notification and data are both set: the notification is displayed by the system "+
+			"and the data only reaches the app when the user taps it")
+	}
+
+	if m.Android != nil {
+		if m.Data != "" && m.Android.Data != "" && m.Data != m.Android.Data {
+			warnings = append(warnings, "android.data overrides data for android devices")
+		}
+
+		if m.Notification != nil && m.Android.Notification != nil {
+			warnings = append(warnings, "android.notification overrides notification fields for android devices")
+		}
+	}
+	return append(warnings, m.Conflicts()...)
+}
+
+// Conflicts returns the contradictions of PlatformConflicts and of AndroidNotification.DefaultConflicts
+// These are part of Validate, config.Config.StrictValidation makes sending fail on them
+func (m *Message) Conflicts() []string {
+	conflicts := m.PlatformConflicts()
+	if m.Android != nil {
+		conflicts = append(conflicts, m.Android.Notification.DefaultConflicts()...)
+	}
+	return conflicts
+}
+
+// PlatformConflicts returns contradictions between the platform blocks of a cross-platform message,
+// like a high android urgency with a power considerate apns priority
+func (m *Message) PlatformConflicts() []string {
+	if m.Android == nil {
+		return nil
+	}
+
+	var conflicts []string
+	high := m.Android.Urgency == constant.DeliveryPriorityHigh
+	normal := m.Android.Urgency == constant.DeliveryPriorityNormal
+
+	if m.Apns != nil && m.Apns.Headers != nil {
+		priority := m.Apns.Headers.ApnsPriority
+		if (high && priority == constant.ApnsPriorityPowerConsiderate) || (normal && priority == constant.ApnsPriorityImmediate) {
+			conflicts = append(conflicts, "android.urgency "+m.Android.Urgency+" contradicts apns-priority "+priority)
+		}
+	}
+
+	if m.WebPush != nil && m.WebPush.Headers != nil {
+		urgency := m.WebPush.Headers.Urgency
+		if (high && (urgency == constant.UrgencyLow || urgency == constant.UrgencyVeryLow)) || (normal && urgency == constant.UrgencyHigh) {
+			conflicts = append(conflicts, "android.urgency "+m.Android.Urgency+" contradicts webpush urgency "+urgency)
+		}
+	}
+
+	if m.Android.FastAppTarget != 0 && m.Apns != nil {
+		conflicts = append(conflicts, "android.fast_app_target targets quick apps, which the apns config never reaches")
+	}
+	return conflicts
+}
+
+// Platform returns the platform the message targets, one of constant.PlatformApp,
+// constant.PlatformWeb or constant.PlatformFastApp
+func (m *Message) Platform() string {
+	if m.WebPush != nil {
+		return constant.PlatformWeb
+	}
+	if m.Android != nil && m.Android.FastAppTarget != 0 {
+		return constant.PlatformFastApp
+	}
+	return constant.PlatformApp
+}
+
 //NewTransparentMsgRequest will return a new MessageRequest instance with default value to send transparent message.
 //developers should set at least on of Message.Token or  Message.Topic or Message.Condition
 func NewTransparentMsgRequest() *MessageRequest {
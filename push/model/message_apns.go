@@ -0,0 +1,185 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Alert returns an empty alert dictionary to build with the With methods
+func Alert() *AlertDictionary {
+	return &AlertDictionary{}
+}
+
+// WithTitle sets the alert title
+func (a *AlertDictionary) WithTitle(title string) *AlertDictionary {
+	a.Title = title
+	return a
+}
+
+// WithSubtitle sets the line shown below the title
+func (a *AlertDictionary) WithSubtitle(subtitle string) *AlertDictionary {
+	a.Subtitle = subtitle
+	return a
+}
+
+// WithBody sets the alert body
+func (a *AlertDictionary) WithBody(body string) *AlertDictionary {
+	a.Body = body
+	return a
+}
+
+// WithTitleLocKey sets the localized title key of the app and its format arguments
+func (a *AlertDictionary) WithTitleLocKey(key string, args ...string) *AlertDictionary {
+	a.TitleLocKey = key
+	a.TitleLocArgs = args
+	return a
+}
+
+// WithLocKey sets the localized body key of the app and its format arguments
+func (a *AlertDictionary) WithLocKey(key string, args ...string) *AlertDictionary {
+	a.LocKey = key
+	a.LocArgs = args
+	return a
+}
+
+// WithAlert sets the alert, either an *AlertDictionary or a string used as body
+func (a *Aps) WithAlert(alert interface{}) *Aps {
+	a.Alert = alert
+	return a
+}
+
+// WithBadge sets the app icon badge number
+func (a *Aps) WithBadge(badge int) *Aps {
+	a.Badge = badge
+	return a
+}
+
+// WithSound sets the name of the sound file to play
+func (a *Aps) WithSound(sound string) *Aps {
+	a.Sound = sound
+	return a
+}
+
+// WithContentAvailable sets content-available to wake the app in the background, e.g. for silent pushes
+func (a *Aps) WithContentAvailable() *Aps {
+	a.ContentAvailable = 1
+	return a
+}
+
+// WithMutableContent sets mutable-content, which hands the notification to the app's notification service extension
+func (a *Aps) WithMutableContent() *Aps {
+	a.MutableContent = 1
+	return a
+}
+
+// WithAps sets the aps dictionary of the payload, keeping the other payload keys
+func (a *Apns) WithAps(aps *Aps) *Apns {
+	if a.Payload == nil {
+		a.Payload = map[string]interface{}{}
+	}
+	a.Payload["aps"] = aps
+	return a
+}
+
+// WithHeaders sets the apns headers
+func (a *Apns) WithHeaders(headers *ApnsHeaders) *Apns {
+	a.Headers = headers
+	return a
+}
+
+// WithPushType sets apns-push-type, one of the constant.ApnsPushType values
+func (h *ApnsHeaders) WithPushType(pushType string) *ApnsHeaders {
+	h.ApnsPushType = pushType
+	return h
+}
+
+// Set sets an apns header without a field of its own, the name is lowercased
+func (h *ApnsHeaders) Set(name string, value string) *ApnsHeaders {
+	if h.Custom == nil {
+		h.Custom = map[string]string{}
+	}
+	h.Custom[strings.ToLower(name)] = value
+	return h
+}
+
+// MarshalJSON writes the headers as the string map HMS relays to APNs
+func (h ApnsHeaders) MarshalJSON() ([]byte, error) {
+	headers := make(map[string]string, len(h.Custom)+7)
+	for name, value := range h.Custom {
+		headers[name] = value
+	}
+
+	set := func(name string, value string) error {
+		if value == "" {
+			return nil
+		}
+		if _, ok := h.Custom[name]; ok {
+			return fmt.Errorf("apns header %s is set both as a field and with Set", name)
+		}
+		headers[name] = value
+		return nil
+	}
+
+	expiration := ""
+	if h.ApnsExpiration != 0 {
+		expiration = strconv.FormatInt(h.ApnsExpiration, 10)
+	}
+	for _, header := range [][2]string{
+		{"authorization", h.Authorization},
+		{"apns-id", h.ApnsId},
+		{"apns-expiration", expiration},
+		{"apns-priority", h.ApnsPriority},
+		{"apns-topic", h.ApnsTopic},
+		{"apns-collapse-id", h.ApnsCollapseId},
+		{"apns-push-type", h.ApnsPushType},
+	} {
+		if err := set(header[0], header[1]); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(headers)
+}
+
+// UnmarshalJSON reads the headers back into their fields, a numeric apns-expiration is accepted too
+func (h *ApnsHeaders) UnmarshalJSON(b []byte) error {
+	var headers map[string]json.RawMessage
+	if err := json.Unmarshal(b, &headers); err != nil {
+		return err
+	}
+
+	*h = ApnsHeaders{}
+	for name, raw := range headers {
+		if name == "apns-expiration" {
+			expiration, err := strconv.ParseInt(strings.Trim(string(raw), `"`), 10, 64)
+			if err != nil {
+				return fmt.Errorf("malformed apns-expiration: %w", err)
+			}
+			h.ApnsExpiration = expiration
+			continue
+		}
+
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return fmt.Errorf("apns header %s: %w", name, err)
+		}
+		switch name {
+		case "authorization":
+			h.Authorization = value
+		case "apns-id":
+			h.ApnsId = value
+		case "apns-priority":
+			h.ApnsPriority = value
+		case "apns-topic":
+			h.ApnsTopic = value
+		case "apns-collapse-id":
+			h.ApnsCollapseId = value
+		case "apns-push-type":
+			h.ApnsPushType = value
+		default:
+			h.Set(name, value)
+		}
+	}
+	return nil
+}
@@ -17,6 +17,9 @@ Copyright 2020. Huawei Technologies Co., Ltd. All rights reserved.
 package model
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/dafanasiev/go-hms-push/push/constant"
 )
 
@@ -26,9 +29,11 @@ type AndroidConfig struct {
 	Category      string               `json:"category,omitempty"`
 	TTL           string               `json:"ttl,omitempty"`
 	BiTag         string               `json:"bi_tag,omitempty"`
+	AnalyticInfo  AnalyticInfo         `json:"analytic_info,omitempty"`
 	FastAppTarget int                  `json:"fast_app_target,omitempty"`
 	Data          string               `json:"data,omitempty"`
 	Notification  *AndroidNotification `json:"notification,omitempty"`
+	Extra         Extra                `json:"-"`
 }
 
 type AndroidNotification struct {
@@ -52,20 +57,23 @@ type AndroidNotification struct {
 	BigTitle      string                 `json:"big_title,omitempty"`
 	BigBody       string                 `json:"big_body,omitempty"`
 
-	AutoClear         int                `json:"auto_clear,omitempty"`
-	NotifyId          int                `json:"notify_id,omitempty"`
+	// AutoClear, NotifyId, AutoCancel and ForegroundShow are pointers so that an explicit zero or false
+	// is sent while nil leaves the HMS default, set them with Int and Bool
+	AutoClear         *int               `json:"auto_clear,omitempty"`
+	NotifyId          *int               `json:"notify_id,omitempty"`
 	Group             string             `json:"group,omitempty"`
 	Badge             *BadgeNotification `json:"badge,omitempty,omitempty"`
 	Ticker            string             `json:"ticker,omitempty"`
-	AutoCancel        bool               `json:"auto_cancel,omitempty"`
-	when              string             `json:"when,omitempty"`
+	AutoCancel        *bool              `json:"auto_cancel,omitempty"`
+	When              string             `json:"when,omitempty"` // UTC, e.g. 2014-10-02T15:01:23.045123456Z
 	Importance        string             `json:"importance,omitempty"`
 	UseDefaultVibrate bool               `json:"use_default_vibrate,omitempty"`
 	UseDefaultLight   bool               `json:"use_default_light,omitempty"`
 	VibrateConfig     []string           `json:"vibrate_config,omitempty"`
 	Visibility        string             `json:"visibility,omitempty"`
 	LightSettings     *LightSettings     `json:"light_settings,omitempty"`
-	ForegroundShow    bool               `json:"foreground_show,omitempty"`
+	ForegroundShow    *bool              `json:"foreground_show,omitempty"`
+	Extra             Extra              `json:"-"`
 }
 
 type ClickAction struct {
@@ -94,6 +102,143 @@ type Color struct {
 	Blue  int `json:"blue"`
 }
 
+// WithCategory sets the message category, one of the constant.Category values
+func (a *AndroidConfig) WithCategory(category constant.Category) *AndroidConfig {
+	a.Category = string(category)
+	return a
+}
+
+// WithTTL sets how long HMS keeps the message for an offline device, e.g. so that a broadcast
+// isn't delivered once stale
+func (a *AndroidConfig) WithTTL(ttl time.Duration) *AndroidConfig {
+	a.TTL = strconv.FormatFloat(ttl.Seconds(), 'f', -1, 64) + "s"
+	return a
+}
+
+// WithCollapseKey sets the key under which HMS keeps only the latest of the offline messages,
+// from 0 to 100, -1 keeps them all
+func (a *AndroidConfig) WithCollapseKey(collapseKey int) *AndroidConfig {
+	a.CollapseKey = collapseKey
+	return a
+}
+
+// WithBiTag sets the tag HMS reports the delivery receipts and analytics of the message under, e.g. a campaign id
+// HMS only reads it from android.bi_tag, verify.ValidateMessage rejects a bi_tag set at another level with Extra
+func (a *AndroidConfig) WithBiTag(tag string) *AndroidConfig {
+	a.BiTag = tag
+	return a
+}
+
+// AnalyticInfo holds campaign and tracking parameters HMS passes on to AppGallery analytics along with the bi tag
+// Keys and values are bounded by constant.AnalyticInfoMaxKeyLength and constant.AnalyticInfoMaxValueLength
+type AnalyticInfo map[string]string
+
+// WithAnalyticInfo adds a tracking parameter to the android.analytic_info of the message
+// Like bi_tag, HMS only reads it from the android config
+func (a *AndroidConfig) WithAnalyticInfo(key string, value string) *AndroidConfig {
+	if a.AnalyticInfo == nil {
+		a.AnalyticInfo = AnalyticInfo{}
+	}
+	a.AnalyticInfo[key] = value
+	return a
+}
+
+// WithFastAppTarget sets the quick app state the message targets,
+// constant.FastAppTargetDevelop or constant.FastAppTargetProduct
+func (a *AndroidConfig) WithFastAppTarget(target int) *AndroidConfig {
+	a.FastAppTarget = target
+	return a
+}
+
+// WithBadge sets the app icon badge of the notification, either AddNum or SetNum must be used
+func (n *AndroidNotification) WithBadge(badge *BadgeNotification) *AndroidNotification {
+	n.Badge = badge
+	return n
+}
+
+// WithVisibility sets the lock screen visibility of the notification, one of the constant.Visibility values
+// The validation defaults it to constant.VisibilityPrivate when empty
+func (n *AndroidNotification) WithVisibility(visibility string) *AndroidNotification {
+	n.Visibility = visibility
+	return n
+}
+
+// WithImportance sets the display priority of the notification, one of the constant.NotificationPriority values
+func (n *AndroidNotification) WithImportance(importance string) *AndroidNotification {
+	n.Importance = importance
+	return n
+}
+
+// WithDefaultSound makes the notification play the system default sound, no custom Sound must be set
+func (n *AndroidNotification) WithDefaultSound() *AndroidNotification {
+	n.DefaultSound = true
+	return n
+}
+
+// WithDefaultVibrate makes the notification use the system default vibration, no custom VibrateConfig must be set
+func (n *AndroidNotification) WithDefaultVibrate() *AndroidNotification {
+	n.UseDefaultVibrate = true
+	return n
+}
+
+// WithDefaultLight makes the notification use the system default breathing light, no custom LightSettings
+// must be set
+func (n *AndroidNotification) WithDefaultLight() *AndroidNotification {
+	n.UseDefaultLight = true
+	return n
+}
+
+// WithSound sets a custom notification sound, turning DefaultSound off
+func (n *AndroidNotification) WithSound(sound string) *AndroidNotification {
+	n.Sound = sound
+	n.DefaultSound = false
+	return n
+}
+
+// WithVibrateConfig sets custom vibration timings, turning UseDefaultVibrate off
+func (n *AndroidNotification) WithVibrateConfig(timings ...string) *AndroidNotification {
+	n.VibrateConfig = timings
+	n.UseDefaultVibrate = false
+	return n
+}
+
+// WithLightSettings sets a custom breathing light, turning UseDefaultLight off
+func (n *AndroidNotification) WithLightSettings(settings *LightSettings) *AndroidNotification {
+	n.LightSettings = settings
+	n.UseDefaultLight = false
+	return n
+}
+
+// DefaultConflicts returns the system default flags set together with the custom value they override,
+// HMS then ignores the custom value
+func (n *AndroidNotification) DefaultConflicts() []string {
+	if n == nil {
+		return nil
+	}
+
+	var conflicts []string
+	if n.DefaultSound && n.Sound != "" {
+		conflicts = append(conflicts, "android.notification.default_sound ignores the custom sound "+n.Sound)
+	}
+	if n.UseDefaultVibrate && len(n.VibrateConfig) > 0 {
+		conflicts = append(conflicts, "android.notification.use_default_vibrate ignores the custom vibrate_config")
+	}
+	if n.UseDefaultLight && n.LightSettings != nil {
+		conflicts = append(conflicts, "android.notification.use_default_light ignores the custom light_settings")
+	}
+	return conflicts
+}
+
+// Bool returns a pointer to b, for the pointer-optional fields
+func Bool(b bool) *bool {
+	return &b
+}
+
+// Int returns a pointer to i, for the pointer-optional fields
+func Int(i int) *int {
+	return &i
+}
+
 func GetDefaultAndroid() *AndroidConfig {
 	android := &AndroidConfig{
 		Urgency:      constant.DeliveryPriorityNormal,
@@ -113,9 +258,9 @@ func GetDefaultAndroidNotification() *AndroidNotification {
 	notification.UseDefaultVibrate = true
 	notification.UseDefaultLight = true
 	notification.Visibility = constant.VisibilityPrivate
-	notification.ForegroundShow = true
+	notification.ForegroundShow = Bool(true)
 
-	notification.AutoCancel = true
+	notification.AutoCancel = Bool(true)
 
 	return notification
 }
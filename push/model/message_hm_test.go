@@ -0,0 +1,66 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestAndroidNotificationOptionalFieldsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		notification AndroidNotification
+		want         map[string]interface{}
+	}{
+		{"nil fields are omitted", AndroidNotification{}, map[string]interface{}{}},
+		{"explicit zero and false are sent", AndroidNotification{
+			AutoClear:      Int(0),
+			NotifyId:       Int(0),
+			AutoCancel:     Bool(false),
+			ForegroundShow: Bool(false),
+		}, map[string]interface{}{
+			"auto_clear":      0.0,
+			"notify_id":       0.0,
+			"auto_cancel":     false,
+			"foreground_show": false,
+		}},
+		{"set values are sent", AndroidNotification{
+			AutoClear:      Int(1000),
+			NotifyId:       Int(7),
+			AutoCancel:     Bool(true),
+			ForegroundShow: Bool(true),
+			When:           "2026-10-14T10:00:00.000Z",
+		}, map[string]interface{}{
+			"auto_clear":      1000.0,
+			"notify_id":       7.0,
+			"auto_cancel":     true,
+			"foreground_show": true,
+			"when":            "2026-10-14T10:00:00.000Z",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(&tt.notification)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields map[string]interface{}
+			if err = json.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("json = %s, want fields %v", data, tt.want)
+			}
+
+			var decoded AndroidNotification
+			if err = json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, tt.notification) {
+				t.Errorf("decoded = %+v, want %+v", decoded, tt.notification)
+			}
+		})
+	}
+}
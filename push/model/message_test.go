@@ -0,0 +1,67 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMessageWithDataAndNotification(t *testing.T) {
+	message := (&Message{Token: []string{"token"}}).
+		WithData(`{"chat":"1"}`).
+		WithNotification(&Notification{Title: "title", Body: "body"})
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["data"]) != `"{\"chat\":\"1\"}"` {
+		t.Errorf("data = %s in %s", fields["data"], data)
+	}
+	if string(fields["notification"]) != `{"title":"title","body":"body"}` {
+		t.Errorf("notification = %s in %s", fields["notification"], data)
+	}
+}
+
+func TestMessageValidate(t *testing.T) {
+	notification := &Notification{Title: "title"}
+
+	tests := []struct {
+		name    string
+		message *Message
+		want    []string
+	}{
+		{"data only", &Message{Data: "data"}, nil},
+		{"notification only", &Message{Notification: notification}, nil},
+		{"data and notification", &Message{Data: "data", Notification: notification},
+			[]string{"notification and data are both set"}},
+		{"android data and notification", &Message{Notification: notification, Android: &AndroidConfig{Data: "data"}},
+			[]string{"notification and data are both set"}},
+		{"data and android notification", &Message{Data: "data", Android: &AndroidConfig{Notification: &AndroidNotification{}}},
+			[]string{"notification and data are both set"}},
+		{"same android data", &Message{Data: "data", Android: &AndroidConfig{Data: "data"}}, nil},
+		{"different android data", &Message{Data: "data", Android: &AndroidConfig{Data: "other"}},
+			[]string{"android.data overrides data"}},
+		{"both notifications", &Message{Notification: notification, Android: &AndroidConfig{Notification: &AndroidNotification{}}},
+			[]string{"android.notification overrides notification"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := tt.message.Validate()
+			if len(warnings) != len(tt.want) {
+				t.Fatalf("warnings = %q, want %q", warnings, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(warnings[i], want) {
+					t.Errorf("warning %d = %q, want it to start with %q", i, warnings[i], want)
+				}
+			}
+		})
+	}
+}
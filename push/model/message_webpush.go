@@ -27,11 +27,12 @@ type WebPushConfig struct {
 	Headers      *WebPushHeaders      `json:"headers,omitempty"`
 	HmsOptions   *HmsWebPushOption    `json:"hms_options,omitempty"`
 	Notification *WebPushNotification `json:"notification,omitempty"`
+	Extra        Extra                `json:"-"`
 }
 
 type WebPushHeaders struct {
 	TTL     string `json:"ttl,omitempty"`
-	Topic   string `json:"topics,omitempty"`
+	Topic   string `json:"topic,omitempty"`
 	Urgency string `json:"urgency,omitempty"`
 }
 
@@ -0,0 +1,45 @@
+package model
+
+// SendOutcome is a flat projection of a SendResult meant to be marshaled into analytics events
+// Its json field names are stable, the token values are left out unless asked for with OutcomeWithTokens
+type SendOutcome struct {
+	AppId             string `json:"app_id"`
+	ClientMessageId   string `json:"client_message_id,omitempty"`
+	ClientRequestId   string `json:"client_request_id,omitempty"`
+	Endpoint          string `json:"endpoint,omitempty"`
+	RequestId         string `json:"request_id"`
+	Code              string `json:"code"`
+	Kind              string `json:"kind"`
+	SuccessCount      int    `json:"success_count"`
+	FailureCount      int    `json:"failure_count"`
+	IllegalTokenCount int    `json:"illegal_token_count"`
+	// DurationMs is zero unless config.Config.DetailedResults is set
+	DurationMs    int64    `json:"duration_ms"`
+	Attempts      int      `json:"attempts"`
+	IllegalTokens []string `json:"illegal_tokens,omitempty"`
+}
+
+// Outcome returns the SendOutcome of the result, without token values
+func (r *SendResult) Outcome() *SendOutcome {
+	return &SendOutcome{
+		AppId:             r.AppId,
+		ClientMessageId:   r.ClientMessageId,
+		ClientRequestId:   r.ClientRequestId,
+		Endpoint:          r.Endpoint,
+		RequestId:         r.RequestId,
+		Code:              r.Code,
+		Kind:              r.kind,
+		SuccessCount:      r.SuccessCount,
+		FailureCount:      r.FailureCount,
+		IllegalTokenCount: len(r.IllegalTokens),
+		DurationMs:        r.TotalDuration.Milliseconds(),
+		Attempts:          r.AttemptCount,
+	}
+}
+
+// OutcomeWithTokens returns the SendOutcome of the result including the illegal token values
+func (r *SendResult) OutcomeWithTokens() *SendOutcome {
+	outcome := r.Outcome()
+	outcome.IllegalTokens = cloneStrings(r.IllegalTokens)
+	return outcome
+}
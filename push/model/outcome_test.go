@@ -0,0 +1,61 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+)
+
+func TestOutcomeJSON(t *testing.T) {
+	result := &SendResult{
+		AppId:           "app",
+		ClientMessageId: "client-message",
+		ClientRequestId: "client-request",
+		Endpoint:        "backup",
+		Code:            constant.CodePartialSuccess,
+		Msg:             `{"success":1,"failure":2,"illegal_tokens":["b","c"]}`,
+		RequestId:       "request",
+		SuccessCount:    1,
+		FailureCount:    2,
+		IllegalTokens:   []string{"b", "c"},
+		Raw:             []byte(`{"code":"80100000"}`),
+		AttemptCount:    2,
+		TotalDuration:   1500 * time.Millisecond,
+		kind:            constant.TargetKindToken,
+		tokens:          []string{"a", "b", "c"},
+	}
+
+	tests := []struct {
+		name    string
+		outcome *SendOutcome
+		want    string
+	}{
+		{"default", result.Outcome(),
+			`{"app_id":"app","client_message_id":"client-message","client_request_id":"client-request",` +
+				`"endpoint":"backup","request_id":"request","code":"80100000","kind":"token","success_count":1,` +
+				`"failure_count":2,"illegal_token_count":2,"duration_ms":1500,"attempts":2}`},
+		{"with tokens", result.OutcomeWithTokens(),
+			`{"app_id":"app","client_message_id":"client-message","client_request_id":"client-request",` +
+				`"endpoint":"backup","request_id":"request","code":"80100000","kind":"token","success_count":1,` +
+				`"failure_count":2,"illegal_token_count":2,"duration_ms":1500,"attempts":2,"illegal_tokens":["b","c"]}`},
+		// the empty optional fields are left out, the counts are always there
+		{"minimal", (&SendResult{AppId: "app", Code: constant.CodeSuccess, RequestId: "topic-message",
+			kind: constant.TargetKindTopic}).OutcomeWithTokens(),
+			`{"app_id":"app","request_id":"topic-message","code":"80000000","kind":"topic","success_count":0,` +
+				`"failure_count":0,"illegal_token_count":0,"duration_ms":0,"attempts":0}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.outcome)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json = %s\nwant   %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// QuotaStatus is the best-effort estimate of the remaining push quota, built from the responses seen so far
+type QuotaStatus struct {
+	// Known is false until a response carried a quota signal, the other fields are then zero
+	Known bool
+	// Remaining is the number of sends left, zero once the quota is exceeded
+	Remaining int
+	// ResetAt is when the quota resets, zero when no response told
+	ResetAt time.Time
+	// Exceeded is set once HMS rejected a send for quota reasons, until the quota resets
+	Exceeded bool
+	// ObservedAt is the time of the response the estimate comes from
+	ObservedAt time.Time
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/httpclient"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+)
+
+// SendResult is the parsed outcome of a send, whatever the message target
+type SendResult struct {
+	AppId string
+	// ClientMessageId is the id set with core.WithClientMessageId, empty if there was none
+	ClientMessageId string
+	// ClientRequestId is the id the client sent in the X-Request-Id header, RequestId is the one HMS returned
+	ClientRequestId string
+	// Endpoint is the name of the alternate endpoint the send went to, empty for the configured PushUrl
+	Endpoint  string
+	Code      string
+	Msg       string
+	RequestId string
+	// MessageId identifies a topic or condition message, it's empty for token sends
+	MessageId string
+	// SuccessCount, FailureCount and IllegalTokens are only filled for token sends
+	SuccessCount  int
+	FailureCount  int
+	IllegalTokens []string
+	// Raw is a copy of the response body the result was parsed from
+	Raw []byte
+	// AttemptCount is the number of http attempts of the final request
+	AttemptCount int
+	// TotalDuration and Attempts are only filled when config.Config.DetailedResults is set
+	// Attempts covers every http attempt in order, including the resend after a token refresh
+	TotalDuration time.Duration
+	Attempts      []httpclient.Attempt
+
+	kind string
+	// the tokens of a token send, for Retryable
+	tokens []string
+}
+
+// partial success details, sent as a json string in the msg field of the response
+type tokenSendDetails struct {
+	Success       int      `json:"success"`
+	Failure       int      `json:"failure"`
+	IllegalTokens []string `json:"illegal_tokens"`
+}
+
+// Kind returns the message target the result is for, one of the constant.TargetKind values
+func (r *SendResult) Kind() string {
+	return r.kind
+}
+
+// NewSendResult parses the response to a send of message
+func NewSendResult(message *Message, resp *MessageResponse) *SendResult {
+	result := &SendResult{
+		Code:      resp.Code,
+		Msg:       resp.Msg,
+		RequestId: resp.RequestId,
+		kind:      targetKind(message),
+	}
+
+	if result.kind != constant.TargetKindToken {
+		// topic and condition sends are identified by the request id alone
+		result.MessageId = resp.RequestId
+		return result
+	}
+
+	result.tokens = cloneStrings(message.Token)
+	switch resp.Code {
+	case constant.CodeSuccess:
+		result.SuccessCount = len(message.Token)
+	case constant.CodePartialSuccess:
+		var details tokenSendDetails
+		if err := json.Unmarshal([]byte(resp.Msg), &details); err == nil {
+			result.SuccessCount = details.Success
+			result.FailureCount = details.Failure
+			result.IllegalTokens = details.IllegalTokens
+		}
+	case constant.CodeAllTokensInvalid:
+		result.FailureCount = len(message.Token)
+		result.IllegalTokens = cloneStrings(message.Token)
+	}
+	return result
+}
+
+func targetKind(message *Message) string {
+	switch {
+	case message == nil:
+		return ""
+	case message.Token != nil:
+		return constant.TargetKindToken
+	case message.Topic != "":
+		return constant.TargetKindTopic
+	case message.Condition != "":
+		return constant.TargetKindCondition
+	}
+	return ""
+}
+
+// TokenStatus is the approximate outcome of a send for one token, see SendResult.TokenStatuses
+type TokenStatus string
+
+const (
+	// TokenDelivered means the push server accepted the message for the token,
+	// not that the device received it
+	TokenDelivered TokenStatus = "delivered_to_gateway"
+	// TokenIllegal means the push server reported the token as illegal, it should be dropped
+	TokenIllegal TokenStatus = "illegal"
+	// TokenFailed means the whole send failed, the token may be retried
+	TokenFailed TokenStatus = "failed"
+)
+
+// TokenStatuses maps every token of the send to its status
+// HMS only reports the illegal tokens, so the other tokens of a successful or partially successful send
+// are assumed delivered to the gateway, and every token of a failed send is failed
+func (r *SendResult) TokenStatuses(tokens []string) map[string]TokenStatus {
+	statuses := make(map[string]TokenStatus, len(tokens))
+
+	status := TokenDelivered
+	switch r.Code {
+	case constant.CodeSuccess, constant.CodePartialSuccess:
+	case constant.CodeAllTokensInvalid:
+		status = TokenIllegal
+	default:
+		status = TokenFailed
+	}
+	for _, token := range tokens {
+		statuses[token] = status
+	}
+
+	for _, token := range r.IllegalTokens {
+		if _, ok := statuses[token]; ok {
+			statuses[token] = TokenIllegal
+		}
+	}
+	return statuses
+}
+
+// Retryable returns the tokens of the send worth sending again, in sending order
+// HMS only tells illegal tokens apart, so the heuristic works on the result code:
+//   - success, partial success and all tokens invalid: nothing, the tokens not reported illegal were delivered
+//   - a transient failure (quota exceeded, internal error, access token or auth service failure):
+//     every token but the illegal ones
+//   - any other code rejects the message itself, sending it again to the same tokens fails the same way: nothing
+func (r *SendResult) Retryable() []string {
+	if !IsTransientCode(r.Code) {
+		return nil
+	}
+
+	statuses := r.TokenStatuses(r.tokens)
+	var retryable []string
+	for _, token := range r.tokens {
+		if statuses[token] == TokenFailed {
+			retryable = append(retryable, token)
+		}
+	}
+	return retryable
+}
+
+// IsTransientCode reports whether a send failing with the result code may succeed when sent again unchanged
+func IsTransientCode(code string) bool {
+	switch code {
+	case constant.CodeQuotaExceeded, constant.CodeInternalError,
+		constant.CodeTokenFailed, constant.CodeTokenExpired, constant.CodeAuthServiceFailed:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+)
+
+func TestNewSendResult(t *testing.T) {
+	tokens := &Message{Token: []string{"a", "b", "c"}}
+
+	tests := []struct {
+		name    string
+		message *Message
+		resp    *MessageResponse
+		want    *SendResult
+	}{
+		{"token success", tokens,
+			&MessageResponse{Code: constant.CodeSuccess, Msg: "Success", RequestId: "request"},
+			&SendResult{Code: constant.CodeSuccess, Msg: "Success", RequestId: "request", SuccessCount: 3,
+				kind: constant.TargetKindToken, tokens: tokens.Token}},
+		{"token partial success", tokens,
+			&MessageResponse{Code: constant.CodePartialSuccess, RequestId: "request",
+				Msg: `{"success":1,"failure":2,"illegal_tokens":["b","c"]}`},
+			&SendResult{Code: constant.CodePartialSuccess, RequestId: "request",
+				Msg:          `{"success":1,"failure":2,"illegal_tokens":["b","c"]}`,
+				SuccessCount: 1, FailureCount: 2, IllegalTokens: []string{"b", "c"},
+				kind: constant.TargetKindToken, tokens: tokens.Token}},
+		{"token all invalid", tokens,
+			&MessageResponse{Code: constant.CodeAllTokensInvalid, Msg: "all the tokens are invalid", RequestId: "request"},
+			&SendResult{Code: constant.CodeAllTokensInvalid, Msg: "all the tokens are invalid", RequestId: "request",
+				FailureCount: 3, IllegalTokens: tokens.Token, kind: constant.TargetKindToken, tokens: tokens.Token}},
+		{"topic", &Message{Topic: "news"},
+			&MessageResponse{Code: constant.CodeSuccess, Msg: "Success", RequestId: "topic-message"},
+			&SendResult{Code: constant.CodeSuccess, Msg: "Success", RequestId: "topic-message",
+				MessageId: "topic-message", kind: constant.TargetKindTopic}},
+		{"condition", &Message{Condition: "'news' in topics"},
+			&MessageResponse{Code: constant.CodeSuccess, Msg: "Success", RequestId: "condition-message"},
+			&SendResult{Code: constant.CodeSuccess, Msg: "Success", RequestId: "condition-message",
+				MessageId: "condition-message", kind: constant.TargetKindCondition}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewSendResult(tt.message, tt.resp)
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("result = %+v, want %+v", result, tt.want)
+			}
+			if result.Kind() != tt.want.kind {
+				t.Errorf("kind = %q, want %q", result.Kind(), tt.want.kind)
+			}
+		})
+	}
+}
+
+func TestNewSendResultCopiesTokens(t *testing.T) {
+	message := &Message{Token: []string{"a"}}
+	result := NewSendResult(message, &MessageResponse{Code: constant.CodeAllTokensInvalid})
+
+	message.Token[0] = "changed"
+	if result.IllegalTokens[0] != "a" || result.tokens[0] != "a" {
+		t.Errorf("result shares the tokens of the message: %v %v", result.IllegalTokens, result.tokens)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tokens := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name          string
+		code          string
+		tokens        []string
+		illegalTokens []string
+		want          []string
+	}{
+		{"quota exceeded", constant.CodeQuotaExceeded, tokens, nil, tokens},
+		{"internal error", constant.CodeInternalError, tokens, nil, tokens},
+		{"access token failed", constant.CodeTokenFailed, tokens, nil, tokens},
+		{"access token expired", constant.CodeTokenExpired, tokens, nil, tokens},
+		{"auth service failed", constant.CodeAuthServiceFailed, tokens, nil, tokens},
+		{"transient with illegal tokens", constant.CodeInternalError, tokens, []string{"b"}, []string{"a", "c"}},
+		// an illegal token the send didn't carry changes nothing
+		{"transient with foreign illegal token", constant.CodeQuotaExceeded, tokens, []string{"x"}, tokens},
+		{"transient every token illegal", constant.CodeInternalError, tokens, tokens, nil},
+		{"topic send", constant.CodeInternalError, nil, nil, nil},
+		{"success", constant.CodeSuccess, tokens, nil, nil},
+		{"partial success", constant.CodePartialSuccess, tokens, []string{"b"}, nil},
+		{"all tokens invalid", constant.CodeAllTokensInvalid, tokens, tokens, nil},
+		{"invalid message", constant.CodeInvalidMessage, tokens, nil, nil},
+		{"permission denied", constant.CodePermissionDenied, tokens, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &SendResult{Code: tt.code, IllegalTokens: tt.illegalTokens, tokens: tt.tokens}
+			if got := result.Retryable(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package model
+
+type MessageStatsRequest struct {
+	RequestId string `json:"requestId"`
+}
+
+// MessageStats are the aggregate delivery counts of a sent message
+type MessageStats struct {
+	Code      string `json:"code"`
+	Msg       string `json:"msg"`
+	RequestId string `json:"requestId"`
+	// Sent is the number of devices the message was sent to
+	Sent      int `json:"sent"`
+	Delivered int `json:"delivered"`
+	Displayed int `json:"displayed"`
+	Clicked   int `json:"clicked"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "regexp"
+
+// templatePlaceholder matches {name} placeholders, names are letters, digits and underscores
+var templatePlaceholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandTemplate replaces the {name} placeholders of the notification titles and bodies with vars,
+// in the common, android and web push notifications
+// Placeholders without a variable are left as is and returned, each name once
+// The message is modified, expand a Clone to keep a template
+func (m *Message) ExpandTemplate(vars map[string]string) []string {
+	if m == nil {
+		return nil
+	}
+
+	seen := map[string]struct{}{}
+	var unresolved []string
+	expand := func(s *string) {
+		*s = templatePlaceholder.ReplaceAllStringFunc(*s, func(placeholder string) string {
+			name := placeholder[1 : len(placeholder)-1]
+			if value, ok := vars[name]; ok {
+				return value
+			}
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				unresolved = append(unresolved, name)
+			}
+			return placeholder
+		})
+	}
+
+	if m.Notification != nil {
+		expand(&m.Notification.Title)
+		expand(&m.Notification.Body)
+	}
+	if m.Android != nil && m.Android.Notification != nil {
+		expand(&m.Android.Notification.Title)
+		expand(&m.Android.Notification.Body)
+	}
+	if m.WebPush != nil && m.WebPush.Notification != nil {
+		expand(&m.WebPush.Notification.Title)
+		expand(&m.WebPush.Notification.Body)
+	}
+	return unresolved
+}
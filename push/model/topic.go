@@ -0,0 +1,53 @@
+package model
+
+type TopicRequest struct {
+	Topic      string   `json:"topic"`
+	TokenArray []string `json:"tokenArray"`
+}
+
+type TopicResponse struct {
+	Code         string       `json:"code"`
+	Msg          string       `json:"msg"`
+	RequestId    string       `json:"requestId"`
+	SuccessCount int          `json:"successCount"`
+	FailureCount int          `json:"failureCount"`
+	Errors       []TopicError `json:"errors"`
+}
+
+// TopicError is the failure of the token at Index of the request token array
+type TopicError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+type TopicListRequest struct {
+	Token string `json:"token"`
+}
+
+type TopicListResponse struct {
+	Code      string  `json:"code"`
+	Msg       string  `json:"msg"`
+	RequestId string  `json:"requestId"`
+	Topics    []Topic `json:"topics"`
+}
+
+type Topic struct {
+	Name    string `json:"name"`
+	AddDate string `json:"addDate"`
+}
+
+// UnsubscribeAllResult is the outcome of unsubscribing a token from all of its topics
+type UnsubscribeAllResult struct {
+	// Unsubscribed holds the topics the token was removed from
+	Unsubscribed []string
+	// Failed maps the topics the token couldn't be removed from to the reason
+	Failed map[string]error
+}
+
+// SubscribeTopicsResult is the outcome of subscribing tokens to several topics
+type SubscribeTopicsResult struct {
+	// Responses maps the topics the server answered to their response, including per token failures
+	Responses map[string]*TopicResponse
+	// Failed maps the topics the subscription failed for to the reason
+	Failed map[string]error
+}
@@ -0,0 +1,231 @@
+// Package pushtest provides an in-memory HMS server speaking the auth and send protocols,
+// for testing code using this library without reaching HMS
+package pushtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/push/config"
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+const (
+	// AppId and AppSecret are the credentials the server accepts
+	AppId     = "pushtest-app"
+	AppSecret = "pushtest-secret"
+
+	// AuthPath is the path of the auth endpoint on the server
+	AuthPath = "/oauth2/v3/token"
+)
+
+// Request is a request received by the server
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Message decodes the message request of a send
+func (r *Request) Message() (*model.MessageRequest, error) {
+	var msgRequest model.MessageRequest
+	if err := json.Unmarshal(r.Body, &msgRequest); err != nil {
+		return nil, err
+	}
+	return &msgRequest, nil
+}
+
+// Response is a canned reply of the send endpoint
+type Response struct {
+	Status int
+	Header http.Header
+	Body   *model.MessageResponse
+}
+
+// Server is an HMS server backed by httptest
+// Sends are answered with the queued responses in order, then with success
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	requests  []*Request
+	responses []*Response
+	tokens    int
+	token     string
+}
+
+// NewServer starts a server, it must be closed with Close
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Config returns a client config pointing at the server
+func (s *Server) Config() *config.Config {
+	return &config.Config{
+		AppId:         AppId,
+		AppSecret:     AppSecret,
+		AuthUrl:       s.URL + AuthPath,
+		PushUrl:       s.URL,
+		MaxRetryTimes: 1,
+	}
+}
+
+// Respond queues responses for the next sends
+func (s *Server) Respond(responses ...*Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses = append(s.responses, responses...)
+}
+
+// RespondError queues a send answered with the result code
+func (s *Server) RespondError(status int, code string, msg string) {
+	s.Respond(&Response{Status: status, Body: &model.MessageResponse{Code: code, Msg: msg}})
+}
+
+// RespondPartial queues a partially successful token send
+func (s *Server) RespondPartial(success int, illegalTokens ...string) {
+	msg, _ := json.Marshal(map[string]interface{}{
+		"success":        success,
+		"failure":        len(illegalTokens),
+		"illegal_tokens": illegalTokens,
+	})
+	s.RespondError(http.StatusOK, constant.CodePartialSuccess, string(msg))
+}
+
+// RespondQuotaExceeded queues a throttled send, asking to retry after retryAfter when it isn't zero
+func (s *Server) RespondQuotaExceeded(retryAfter time.Duration) {
+	response := &Response{
+		Status: http.StatusTooManyRequests,
+		Header: http.Header{},
+		Body:   &model.MessageResponse{Code: constant.CodeQuotaExceeded, Msg: "quota exceeded"},
+	}
+	if retryAfter > 0 {
+		response.Header.Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+	}
+	s.Respond(response)
+}
+
+// ExpireToken makes the server reject the access token it issued last, like HMS does once it expires
+func (s *Server) ExpireToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = ""
+}
+
+// AcceptToken makes the server accept token as its current access token, for clients given
+// auth.StaticTokenSource(token) instead of fetching one
+func (s *Server) AcceptToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = token
+}
+
+// TokensIssued returns the number of access tokens the auth endpoint issued
+func (s *Server) TokensIssued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tokens
+}
+
+// Requests returns the requests received so far, auth requests included
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Request(nil), s.requests...)
+}
+
+// SendRequests returns the send requests received so far
+func (s *Server) SendRequests() []*Request {
+	var sends []*Request
+	for _, r := range s.Requests() {
+		if strings.HasSuffix(r.Path, "/messages:send") {
+			sends = append(sends, r)
+		}
+	}
+	return sends
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, &Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+
+	switch {
+	case r.URL.Path == AuthPath:
+		s.serveAuth(w, body)
+	case r.URL.Path == fmt.Sprintf(constant.SendMessageFmt, "", AppId):
+		s.serveSend(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveAuth(w http.ResponseWriter, body []byte) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil || form.Get("client_id") != AppId || form.Get("client_secret") != AppSecret {
+		writeJSON(w, http.StatusBadRequest, nil, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.tokens++
+	s.token = fmt.Sprintf("pushtest-token-%d", s.tokens)
+	writeJSON(w, http.StatusOK, nil, map[string]interface{}{"access_token": s.token, "expires_in": 3600})
+}
+
+func (s *Server) serveSend(w http.ResponseWriter, r *http.Request) {
+	if s.token == "" || r.Header.Get("Authorization") != "Bearer "+s.token {
+		writeJSON(w, http.StatusOK, nil, &model.MessageResponse{Code: constant.CodeTokenExpired, Msg: "token expired"})
+		return
+	}
+
+	if len(s.responses) == 0 {
+		writeJSON(w, http.StatusOK, nil, &model.MessageResponse{Code: constant.CodeSuccess, Msg: "Success", RequestId: s.requestId()})
+		return
+	}
+
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+
+	status := response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, response.Header, response.Body)
+}
+
+func (s *Server) requestId() string {
+	return fmt.Sprintf("pushtest-request-%d", len(s.requests))
+}
+
+func writeJSON(w http.ResponseWriter, status int, header http.Header, body interface{}) {
+	for key, values := range header {
+		w.Header()[key] = values
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,138 @@
+package pushtest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	auth "github.com/dafanasiev/go-hms-push/push/authention"
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/core"
+	"github.com/dafanasiev/go-hms-push/push/model"
+	"github.com/dafanasiev/go-hms-push/push/pushtest"
+)
+
+func newClient(t *testing.T) (*core.HMSClient, *pushtest.Server) {
+	t.Helper()
+
+	server := pushtest.NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := core.NewHttpClient(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func send(client *core.HMSClient, tokens ...string) (*model.SendResult, error) {
+	msgRequest := model.NewNotificationMsgRequest()
+	msgRequest.Message.Notification = &model.Notification{Title: "title", Body: "body"}
+	msgRequest.Message.Token = tokens
+	return client.Send(context.Background(), msgRequest)
+}
+
+func TestServerRecordsSends(t *testing.T) {
+	client, server := newClient(t)
+
+	if _, err := send(client, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(server.Requests()) != 2 || server.TokensIssued() != 1 {
+		t.Fatalf("requests = %d, tokens issued = %d, want an auth request and a send", len(server.Requests()), server.TokensIssued())
+	}
+
+	sends := server.SendRequests()
+	if len(sends) != 1 || sends[0].Method != http.MethodPost {
+		t.Fatalf("sends = %+v, want a POST", sends)
+	}
+	msgRequest, err := sends[0].Message()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens := msgRequest.Message.Token; len(tokens) != 2 || tokens[0] != "a" || tokens[1] != "b" {
+		t.Errorf("sent tokens = %v", tokens)
+	}
+}
+
+func TestServerCannedResponses(t *testing.T) {
+	client, server := newClient(t)
+	server.RespondPartial(1, "b")
+	server.RespondError(http.StatusBadRequest, constant.CodeInvalidMessage, "invalid message")
+
+	result, err := send(client, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != constant.CodePartialSuccess || len(result.IllegalTokens) != 1 || result.IllegalTokens[0] != "b" {
+		t.Errorf("partial result = %+v", result)
+	}
+
+	_, err = send(client, "a")
+	var pushErr *core.PushError
+	if !errors.As(err, &pushErr) || pushErr.StatusCode != http.StatusBadRequest || pushErr.Code != constant.CodeInvalidMessage {
+		t.Errorf("error = %v, want a PushError with code %s", err, constant.CodeInvalidMessage)
+	}
+
+	// the queue is drained, the server answers with success again
+	if result, err = send(client, "a"); err != nil || result.Code != constant.CodeSuccess {
+		t.Errorf("result = %+v, error = %v, want success", result, err)
+	}
+}
+
+func TestServerExpireToken(t *testing.T) {
+	client, server := newClient(t)
+
+	if _, err := send(client, "a"); err != nil {
+		t.Fatal(err)
+	}
+	server.ExpireToken()
+
+	if _, err := send(client, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if server.TokensIssued() != 2 {
+		t.Errorf("tokens issued = %d, want a refresh after the expiry", server.TokensIssued())
+	}
+	if sends := server.SendRequests(); len(sends) != 3 {
+		t.Errorf("sends = %d, want the rejected send resent with the new token", len(sends))
+	}
+}
+
+func TestServerRespondQuotaExceeded(t *testing.T) {
+	client, server := newClient(t)
+	server.RespondQuotaExceeded(30 * time.Second)
+
+	_, err := send(client, "a")
+	var quotaErr *core.QuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("error = %v, want a QuotaError", err)
+	}
+	if quotaErr.StatusCode != http.StatusTooManyRequests || quotaErr.RetryAfter != 30*time.Second {
+		t.Errorf("quota error = %+v, want status 429 and retry after 30s", quotaErr)
+	}
+}
+
+func TestServerAcceptToken(t *testing.T) {
+	server := pushtest.NewServer()
+	defer server.Close()
+	server.AcceptToken("static")
+
+	client, err := core.NewHttpClient(server.Config(), core.WithTokenSource(auth.StaticTokenSource("static")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = send(client, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.TokensIssued() != 0 {
+		t.Errorf("tokens issued = %d, want the static token used", server.TokensIssued())
+	}
+	if sends := server.SendRequests(); len(sends) != 1 || sends[0].Header.Get("Authorization") != "Bearer static" {
+		t.Errorf("sends = %+v, want one with the static token", sends)
+	}
+}
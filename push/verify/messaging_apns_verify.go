@@ -0,0 +1,127 @@
+/*
+Copyright 2020. Huawei Technologies Co., Ltd. All rights reserved.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package verify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/model"
+)
+
+// the apns-topic suffix each push type requires, after the bundle id
+var apnsTopicSuffixes = map[string]string{
+	constant.ApnsPushTypeVoip:         ".voip",
+	constant.ApnsPushTypeComplication: ".complication",
+	constant.ApnsPushTypeFileProvider: ".pushkit.fileprovider",
+	constant.ApnsPushTypeLocation:     ".location-query",
+	constant.ApnsPushTypeLiveActivity: ".push-type.liveactivity",
+}
+
+func validateApnsConfig(apns *model.Apns) error {
+	if apns == nil {
+		return nil
+	}
+
+	pushType := ""
+	if apns.Headers != nil {
+		pushType = apns.Headers.ApnsPushType
+	}
+
+	if err := validateApnsPayloadSize(apns.Payload, pushType); err != nil {
+		return err
+	}
+
+	if pushType == "" {
+		return nil
+	}
+
+	topic := apns.Headers.ApnsTopic
+	switch pushType {
+	case constant.ApnsPushTypeAlert:
+		if !apsOf(apns.Payload).alerts {
+			return errors.New("apns-push-type alert needs an aps alert, badge or sound")
+		}
+	case constant.ApnsPushTypeBackground:
+		aps := apsOf(apns.Payload)
+		if !aps.contentAvailable || aps.alerts {
+			return errors.New("apns-push-type background needs aps content-available without alert, badge or sound")
+		}
+		if apns.Headers.ApnsPriority == constant.ApnsPriorityImmediate {
+			return errors.New("apns-push-type background must not use apns-priority 10")
+		}
+	case constant.ApnsPushTypeMdm:
+	default:
+		suffix, ok := apnsTopicSuffixes[pushType]
+		if !ok {
+			return fmt.Errorf("unknown apns-push-type %q", pushType)
+		}
+		if !strings.HasSuffix(topic, suffix) {
+			return fmt.Errorf("apns-push-type %s needs an apns-topic ending with %s", pushType, suffix)
+		}
+	}
+	return nil
+}
+
+func validateApnsPayloadSize(payload map[string]interface{}, pushType string) error {
+	if payload == nil {
+		return nil
+	}
+
+	limit := constant.ApnsMaxPayloadSize
+	if pushType == constant.ApnsPushTypeVoip {
+		limit = constant.ApnsMaxVoipPayloadSize
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("malformed apns payload: %w", err)
+	}
+	if len(b) > limit {
+		return fmt.Errorf("apns payload is %d bytes, more than the %d bytes apns accepts", len(b), limit)
+	}
+	return nil
+}
+
+// apsShape is what the push type checks need to know of the aps dictionary
+type apsShape struct {
+	alerts           bool
+	contentAvailable bool
+}
+
+// apsOf reads the aps dictionary of a payload, set as *model.Aps, model.Aps or a json map
+func apsOf(payload map[string]interface{}) apsShape {
+	switch aps := payload["aps"].(type) {
+	case *model.Aps:
+		if aps == nil {
+			return apsShape{}
+		}
+		return apsShape{alerts: aps.Alert != nil || aps.Badge != 0 || aps.Sound != "", contentAvailable: aps.ContentAvailable == 1}
+	case model.Aps:
+		return apsOf(map[string]interface{}{"aps": &aps})
+	case map[string]interface{}:
+		_, alert := aps["alert"]
+		_, badge := aps["badge"]
+		_, sound := aps["sound"]
+		contentAvailable := fmt.Sprint(aps["content-available"]) == "1"
+		return apsShape{alerts: alert || badge || sound, contentAvailable: contentAvailable}
+	}
+	return apsShape{}
+}
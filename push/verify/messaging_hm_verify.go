@@ -18,6 +18,9 @@ package verify
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf8"
 
 	"github.com/dafanasiev/go-hms-push/push/constant"
 	"github.com/dafanasiev/go-hms-push/push/model"
@@ -38,6 +41,10 @@ func validateAndroidConfig(androidConfig *model.AndroidConfig) error {
 		return errors.New("delivery_priority must be 'HIGH' or 'NORMAL'")
 	}
 
+	if err := validateAndroidCategory(androidConfig); err != nil {
+		return err
+	}
+
 	if androidConfig.TTL != "" && !ttlPattern.MatchString(androidConfig.TTL) {
 		return errors.New("malformed ttl")
 	}
@@ -48,10 +55,56 @@ func validateAndroidConfig(androidConfig *model.AndroidConfig) error {
 		return errors.New("invalid fast_app_target")
 	}
 
+	if err := validateAnalyticInfo(androidConfig.AnalyticInfo); err != nil {
+		return err
+	}
+
 	// validate android notification
 	return validateAndroidNotification(androidConfig.Notification)
 }
 
+func validateAnalyticInfo(info model.AnalyticInfo) error {
+	keys := make([]string, 0, len(info))
+	for key := range info {
+		keys = append(keys, key)
+	}
+	// sorted for the same message to always fail on the same entry
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "" {
+			return errors.New("analytic_info keys can't be empty")
+		}
+		if utf8.RuneCountInString(key) > constant.AnalyticInfoMaxKeyLength {
+			return fmt.Errorf("analytic_info key %q is longer than %d characters", key, constant.AnalyticInfoMaxKeyLength)
+		}
+		if utf8.RuneCountInString(info[key]) > constant.AnalyticInfoMaxValueLength {
+			return fmt.Errorf("analytic_info value of %q is longer than %d characters", key, constant.AnalyticInfoMaxValueLength)
+		}
+	}
+	return nil
+}
+
+func validateAndroidCategory(androidConfig *model.AndroidConfig) error {
+	switch constant.Category(androidConfig.Category) {
+	case "":
+		// a category is optional whatever the delivery priority, HMS classifies a message without one
+		// itself, and the HIGH priority messages sent before categories were checked keep passing
+		return nil
+	case constant.CategoryMarketing:
+		if androidConfig.Urgency == constant.DeliveryPriorityHigh {
+			return errors.New("category 'MARKETING' can't be sent with delivery_priority 'HIGH'")
+		}
+	case constant.CategoryIM, constant.CategoryVoIP, constant.CategorySubscription, constant.CategoryTravel,
+		constant.CategoryHealth, constant.CategoryWork, constant.CategoryAccount, constant.CategoryExpress,
+		constant.CategoryFinance, constant.CategoryDeviceReminder, constant.CategorySystemReminder,
+		constant.CategoryMail, constant.CategoryPlayVoice:
+	default:
+		return errors.New("invalid category")
+	}
+	return nil
+}
+
 func validateAndroidNotification(notification *model.AndroidNotification) error {
 	if notification == nil {
 		return nil
@@ -81,6 +134,10 @@ func validateAndroidNotification(notification *model.AndroidNotification) error
 		return err
 	}
 
+	if err := validateBadge(notification.Badge); err != nil {
+		return err
+	}
+
 	if notification.Color != "" && !colorPattern.MatchString(notification.Color) {
 		return errors.New("color must be in the form #RRGGBB")
 	}
@@ -161,6 +218,29 @@ func validateLightSetting(notification *model.AndroidNotification) error {
 	return nil
 }
 
+func validateBadge(badge *model.BadgeNotification) error {
+	if badge == nil {
+		return nil
+	}
+
+	if badge.Class == "" {
+		return errors.New("badge.class must not be empty")
+	}
+
+	if badge.AddNum != 0 && badge.SetNum != 0 {
+		return errors.New("badge.add_num and badge.set_num can't be both set")
+	}
+
+	if badge.AddNum < 0 || badge.AddNum > 99 {
+		return errors.New("badge.add_num must be in interval [1 - 99]")
+	}
+
+	if badge.SetNum < 0 || badge.SetNum > 99 {
+		return errors.New("badge.set_num must be in interval [0 - 99]")
+	}
+	return nil
+}
+
 func validateClickAction(clickAction *model.ClickAction) error {
 	if clickAction == nil {
 		return errors.New("click_action object must not be null")
@@ -0,0 +1,150 @@
+package verify_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/model"
+	"github.com/dafanasiev/go-hms-push/push/verify"
+)
+
+func androidMessage(android *model.AndroidConfig) *model.Message {
+	return &model.Message{Token: []string{"token"}, Android: android}
+}
+
+func TestCategorySerialization(t *testing.T) {
+	categories := map[constant.Category]string{
+		constant.CategoryIM:             "IM",
+		constant.CategoryVoIP:           "VOIP",
+		constant.CategorySubscription:   "SUBSCRIPTION",
+		constant.CategoryTravel:         "TRAVEL",
+		constant.CategoryHealth:         "HEALTH",
+		constant.CategoryWork:           "WORK",
+		constant.CategoryAccount:        "ACCOUNT",
+		constant.CategoryExpress:        "EXPRESS",
+		constant.CategoryFinance:        "FINANCE",
+		constant.CategoryDeviceReminder: "DEVICE_REMINDER",
+		constant.CategorySystemReminder: "SYSTEM_REMINDER",
+		constant.CategoryMail:           "MAIL",
+		constant.CategoryPlayVoice:      "PLAY_VOICE",
+		constant.CategoryMarketing:      "MARKETING",
+	}
+
+	for category, want := range categories {
+		data, err := json.Marshal(androidMessage((&model.AndroidConfig{}).WithCategory(category)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var fields struct {
+			Android map[string]interface{} `json:"android"`
+		}
+		if err = json.Unmarshal(data, &fields); err != nil {
+			t.Fatal(err)
+		}
+		if got := fields.Android["category"]; got != want {
+			t.Errorf("android.category = %v, want %s in %s", got, want, data)
+		}
+	}
+}
+
+func TestValidateCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		category constant.Category
+		urgency  string
+		wantErr  bool
+	}{
+		// HMS takes a high priority message without category, see validateAndroidCategory
+		{"high priority without category", "", constant.DeliveryPriorityHigh, false},
+		{"normal priority without category", "", constant.DeliveryPriorityNormal, false},
+		{"im with high priority", constant.CategoryIM, constant.DeliveryPriorityHigh, false},
+		{"voip with high priority", constant.CategoryVoIP, constant.DeliveryPriorityHigh, false},
+		{"marketing with normal priority", constant.CategoryMarketing, constant.DeliveryPriorityNormal, false},
+		{"marketing with high priority", constant.CategoryMarketing, constant.DeliveryPriorityHigh, true},
+		{"unknown category", "GAMES", constant.DeliveryPriorityNormal, true},
+		{"lowercase category", "im", constant.DeliveryPriorityNormal, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			android := (&model.AndroidConfig{Urgency: tt.urgency}).WithCategory(tt.category)
+			err := verify.ValidateMessage(androidMessage(android))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFastAppTarget(t *testing.T) {
+	for target, wantErr := range map[int]bool{
+		0:                             false,
+		constant.FastAppTargetDevelop: false,
+		constant.FastAppTargetProduct: false,
+		3:                             true,
+	} {
+		err := verify.ValidateMessage(androidMessage((&model.AndroidConfig{}).WithFastAppTarget(target)))
+		if (err != nil) != wantErr {
+			t.Errorf("fast_app_target %d: error = %v, want error %t", target, err, wantErr)
+		}
+	}
+}
+
+func TestTopicBroadcastTTLAndCollapseKey(t *testing.T) {
+	android := func() *model.AndroidConfig {
+		return (&model.AndroidConfig{}).WithTTL(time.Hour).WithCollapseKey(1)
+	}
+
+	broadcast := &model.Message{Topic: "news", Android: android()}
+	if err := verify.ValidateMessage(broadcast); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(broadcast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want interface{}
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal([]byte(`{"topic":"news","android":{"collapse_key":1,"ttl":"3600s"}}`), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("json = %s, want %v", data, want)
+	}
+
+	// the android config of a topic send serializes like the one of a token send
+	tokenData, err := json.Marshal(androidMessage(android()).Android)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topicData, err := json.Marshal(broadcast.Android)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tokenData) != string(topicData) {
+		t.Errorf("topic android config = %s, token android config = %s", topicData, tokenData)
+	}
+}
+
+func TestWithTTL(t *testing.T) {
+	for ttl, want := range map[time.Duration]string{
+		time.Hour:               "3600s",
+		1500 * time.Millisecond: "1.5s",
+		15 * 24 * time.Hour:     "1296000s",
+	} {
+		android := (&model.AndroidConfig{}).WithTTL(ttl)
+		if android.TTL != want {
+			t.Errorf("ttl of %s = %q, want %q", ttl, android.TTL, want)
+		}
+		if err := verify.ValidateMessage(androidMessage(android)); err != nil {
+			t.Errorf("ttl of %s: %v", ttl, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package verify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ValidateRawMessage checks the structure of a pre-serialized message: a JSON object with exactly one
+// of the token, topic and condition targets
+// A top level bi_tag or analytic_info is rejected too, HMS only reads them from the android config
+// The rest of the message is sent as is, unlike ValidateMessage it doesn't check the field values
+func ValidateRawMessage(message json.RawMessage) error {
+	if len(message) == 0 {
+		return errors.New("message must not be null")
+	}
+
+	var target struct {
+		Token     []string `json:"token"`
+		Topic     string   `json:"topic"`
+		Condition string   `json:"condition"`
+
+		BiTag        json.RawMessage `json:"bi_tag"`
+		AnalyticInfo json.RawMessage `json:"analytic_info"`
+	}
+	if err := json.Unmarshal(message, &target); err != nil {
+		return fmt.Errorf("message must be a json object: %w", err)
+	}
+
+	if err := validateFieldTarget(target.Token, target.Topic, target.Condition); err != nil {
+		return err
+	}
+	if target.BiTag != nil {
+		return errors.New("bi_tag must be set in the android config, not the message")
+	}
+	if target.AnalyticInfo != nil {
+		return errors.New("analytic_info must be set in the android config, not the message")
+	}
+	return validateTokens(target.Token)
+}
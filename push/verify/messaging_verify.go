@@ -18,11 +18,17 @@ package verify
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"strings"
 
+	"github.com/dafanasiev/go-hms-push/push/constant"
 	"github.com/dafanasiev/go-hms-push/push/model"
 )
 
+// androidOnlyFields are the fields HMS only reads from the android config
+var androidOnlyFields = []string{"bi_tag", "analytic_info"}
+
 var (
 	ttlPattern   = regexp.MustCompile("\\d+|\\d+[sS]|\\d+.\\d{1,9}|\\d+.\\d{1,9}[sS]")
 	colorPattern = regexp.MustCompile("^#[0-9a-fA-F]{6}$")
@@ -38,6 +44,26 @@ func ValidateMessage(message *model.Message) error {
 		return err
 	}
 
+	// validate the token list isn't empty and has no blank tokens
+	if err := validateTokens(message.Token); err != nil {
+		return err
+	}
+
+	// validate the message targets a single platform
+	if err := validatePlatform(message); err != nil {
+		return err
+	}
+
+	// validate the analytics fields aren't set where HMS ignores them
+	if err := validateAndroidOnlyFields(message); err != nil {
+		return err
+	}
+
+	// validate the data payloads fit
+	if err := validateDataSize(message); err != nil {
+		return err
+	}
+
 	// validate android config
 	if err := validateAndroidConfig(message.Android); err != nil {
 		return err
@@ -47,6 +73,63 @@ func ValidateMessage(message *model.Message) error {
 	if err := validateWebPushConfig(message.WebPush); err != nil {
 		return err
 	}
+
+	// validate apns config
+	return validateApnsConfig(message.Apns)
+}
+
+// validateAndroidOnlyFields rejects the analytics fields added with Extra outside of the android config,
+// HMS would accept the message and silently drop them
+func validateAndroidOnlyFields(message *model.Message) error {
+	for _, field := range androidOnlyFields {
+		if _, ok := message.Extra[field]; ok {
+			return fmt.Errorf("%s must be set in the android config, not the message", field)
+		}
+		if message.Android != nil && message.Android.Notification != nil {
+			if _, ok := message.Android.Notification.Extra[field]; ok {
+				return fmt.Errorf("%s must be set in the android config, not the android notification", field)
+			}
+		}
+		if message.WebPush != nil {
+			if _, ok := message.WebPush.Extra[field]; ok {
+				return fmt.Errorf("%s must be set in the android config, not the web push config", field)
+			}
+		}
+	}
+	return nil
+}
+
+func validateDataSize(message *model.Message) error {
+	if len(message.Data) > constant.MaxDataSize {
+		return fmt.Errorf("data is %d bytes, more than the %d bytes limit", len(message.Data), constant.MaxDataSize)
+	}
+	if message.Android != nil && len(message.Android.Data) > constant.MaxDataSize {
+		return fmt.Errorf("android data is %d bytes, more than the %d bytes limit", len(message.Android.Data), constant.MaxDataSize)
+	}
+	return nil
+}
+
+func validateTokens(tokens []string) error {
+	if tokens == nil {
+		return nil
+	}
+
+	if len(tokens) == 0 {
+		return errors.New("token must not be empty")
+	}
+
+	for _, token := range tokens {
+		if strings.TrimSpace(token) == "" {
+			return errors.New("token must not contain blank values")
+		}
+	}
+	return nil
+}
+
+func validatePlatform(message *model.Message) error {
+	if message.WebPush != nil && message.Android != nil && message.Android.FastAppTarget != 0 {
+		return errors.New("webpush can't be combined with android fast_app_target")
+	}
 	return nil
 }
 
@@ -0,0 +1,151 @@
+package verify_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/model"
+	"github.com/dafanasiev/go-hms-push/push/verify"
+)
+
+func TestBiTagSerialization(t *testing.T) {
+	message := androidMessage((&model.AndroidConfig{}).WithBiTag("campaign-42"))
+	if err := verify.ValidateMessage(message); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the location of the send api reference: message.android.bi_tag
+	var got, want interface{}
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal([]byte(`{"token":["token"],"android":{"bi_tag":"campaign-42"}}`), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("json = %s, want %v", data, want)
+	}
+}
+
+func TestValidateMisplacedBiTag(t *testing.T) {
+	withExtra := func(extra *model.Extra) {
+		if err := extra.Set("bi_tag", "campaign-42"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		message func() *model.Message
+	}{
+		{"message", func() *model.Message {
+			message := androidMessage(&model.AndroidConfig{})
+			withExtra(&message.Extra)
+			return message
+		}},
+		{"android notification", func() *model.Message {
+			message := androidMessage(&model.AndroidConfig{Notification: &model.AndroidNotification{}})
+			withExtra(&message.Android.Notification.Extra)
+			return message
+		}},
+		{"web push", func() *model.Message {
+			message := &model.Message{Token: []string{"token"}, WebPush: &model.WebPushConfig{}}
+			withExtra(&message.WebPush.Extra)
+			return message
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verify.ValidateMessage(tt.message()); err == nil {
+				t.Error("bi_tag outside of the android config was accepted")
+			}
+		})
+	}
+}
+
+func TestValidateRawMessageBiTag(t *testing.T) {
+	if err := verify.ValidateRawMessage(json.RawMessage(`{"token":["token"],"android":{"bi_tag":"campaign-42"}}`)); err != nil {
+		t.Errorf("android bi_tag: %v", err)
+	}
+	if err := verify.ValidateRawMessage(json.RawMessage(`{"token":["token"],"bi_tag":"campaign-42"}`)); err == nil {
+		t.Error("top level bi_tag was accepted")
+	}
+}
+
+func TestAnalyticInfoSerialization(t *testing.T) {
+	android := (&model.AndroidConfig{}).
+		WithBiTag("campaign-42").
+		WithAnalyticInfo("utm_source", "newsletter").
+		WithAnalyticInfo("utm_campaign", "autumn")
+	message := androidMessage(android)
+	if err := verify.ValidateMessage(message); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want interface{}
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	err = json.Unmarshal([]byte(`{"token":["token"],"android":{"bi_tag":"campaign-42",`+
+		`"analytic_info":{"utm_source":"newsletter","utm_campaign":"autumn"}}}`), &want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("json = %s, want %v", data, want)
+	}
+}
+
+func TestValidateAnalyticInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{"short", "utm_source", "newsletter", false},
+		{"longest key", strings.Repeat("k", constant.AnalyticInfoMaxKeyLength), "value", false},
+		{"longest value", "key", strings.Repeat("v", constant.AnalyticInfoMaxValueLength), false},
+		{"longest multibyte value", "key", strings.Repeat("é", constant.AnalyticInfoMaxValueLength), false},
+		{"empty key", "", "value", true},
+		{"long key", strings.Repeat("k", constant.AnalyticInfoMaxKeyLength+1), "value", true},
+		{"long value", "key", strings.Repeat("v", constant.AnalyticInfoMaxValueLength+1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			android := (&model.AndroidConfig{}).WithAnalyticInfo(tt.key, tt.value)
+			err := verify.ValidateMessage(androidMessage(android))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMisplacedAnalyticInfo(t *testing.T) {
+	message := androidMessage(&model.AndroidConfig{})
+	if err := message.Extra.Set("analytic_info", map[string]string{"utm_source": "newsletter"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify.ValidateMessage(message); err == nil {
+		t.Error("analytic_info outside of the android config was accepted")
+	}
+
+	raw := json.RawMessage(`{"token":["token"],"analytic_info":{"utm_source":"newsletter"}}`)
+	if err := verify.ValidateRawMessage(raw); err == nil {
+		t.Error("top level analytic_info of a raw message was accepted")
+	}
+}
@@ -18,6 +18,7 @@ package verify
 
 import (
 	"errors"
+	"net/url"
 
 	"github.com/dafanasiev/go-hms-push/push/constant"
 	"github.com/dafanasiev/go-hms-push/push/model"
@@ -32,6 +33,10 @@ func validateWebPushConfig(webPushConfig *model.WebPushConfig) error {
 		return err
 	}
 
+	if err := validateWebPushHmsOptions(webPushConfig.HmsOptions); err != nil {
+		return err
+	}
+
 	return validateWebPushNotification(webPushConfig.Notification)
 }
 
@@ -54,6 +59,18 @@ func validateWebPushHeaders(headers *model.WebPushHeaders) error {
 	return nil
 }
 
+func validateWebPushHmsOptions(hmsOptions *model.HmsWebPushOption) error {
+	if hmsOptions == nil || hmsOptions.Link == "" {
+		return nil
+	}
+
+	link, err := url.Parse(hmsOptions.Link)
+	if err != nil || link.Scheme != "https" || link.Host == "" {
+		return errors.New("web common hms_options.link must be an https url")
+	}
+	return nil
+}
+
 func validateWebPushNotification(notification *model.WebPushNotification) error {
 	if notification == nil {
 		return nil
@@ -83,7 +100,7 @@ func validateWebPushAction(actions []*model.WebPushAction) error {
 }
 
 func validateWebPushDirection(dir string) error {
-	if dir != constant.DirAuto && dir != constant.DirLtr && dir != constant.DirRtl {
+	if dir != "" && dir != constant.DirAuto && dir != constant.DirLtr && dir != constant.DirRtl {
 		return errors.New("web common dir must be 'auto', 'ltr', 'rtl'")
 	}
 	return nil
@@ -0,0 +1,115 @@
+package verify_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/push/constant"
+	"github.com/dafanasiev/go-hms-push/push/model"
+	"github.com/dafanasiev/go-hms-push/push/verify"
+)
+
+func webPushMessage(webPush *model.WebPushConfig) *model.Message {
+	return &model.Message{Token: []string{"token"}, WebPush: webPush}
+}
+
+// documentedWebPush is the web push message of the HMS send api reference
+const documentedWebPush = `{
+	"token": ["token"],
+	"webpush": {
+		"data": "web push data",
+		"headers": {"ttl": "990", "topic": "topic", "urgency": "very-low"},
+		"hms_options": {"link": "https://www.huawei.com"},
+		"notification": {
+			"title": "title",
+			"body": "body",
+			"actions": [{"action": "open", "icon": "https://www.huawei.com/icon.png", "title": "Open"}],
+			"badge": "https://www.huawei.com/badge.png",
+			"dir": "rtl",
+			"icon": "https://www.huawei.com/icon.png",
+			"image": "https://www.huawei.com/image.png",
+			"lang": "ar",
+			"renotify": true,
+			"require_interaction": true,
+			"silent": true,
+			"tag": "tag",
+			"timestamp": 1700000000,
+			"vibrate": [100, 200, 100]
+		}
+	}
+}`
+
+func TestWebPushSerialization(t *testing.T) {
+	message := webPushMessage(&model.WebPushConfig{
+		Data:       "web push data",
+		Headers:    &model.WebPushHeaders{TTL: "990", Topic: "topic", Urgency: constant.UrgencyVeryLow},
+		HmsOptions: &model.HmsWebPushOption{Link: "https://www.huawei.com"},
+		Notification: &model.WebPushNotification{
+			Title: "title",
+			Body:  "body",
+			Actions: []*model.WebPushAction{
+				{Action: "open", Icon: "https://www.huawei.com/icon.png", Title: "Open"},
+			},
+			Badge:              "https://www.huawei.com/badge.png",
+			Dir:                constant.DirRtl,
+			Icon:               "https://www.huawei.com/icon.png",
+			Image:              "https://www.huawei.com/image.png",
+			Lang:               "ar",
+			Renotify:           true,
+			RequireInteraction: true,
+			Silent:             true,
+			Tag:                "tag",
+			Timestamp:          1700000000,
+			Vibrate:            []int{100, 200, 100},
+		},
+	})
+	if err := verify.ValidateMessage(message); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got, want interface{}
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal([]byte(documentedWebPush), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("json = %s, want %s", data, documentedWebPush)
+	}
+}
+
+func TestValidateWebPush(t *testing.T) {
+	tests := []struct {
+		name    string
+		webPush *model.WebPushConfig
+		wantErr bool
+	}{
+		{"default", model.GetDefaultWebPushConfig(), false},
+		{"ttl in seconds", &model.WebPushConfig{Headers: &model.WebPushHeaders{TTL: "86400s"}}, false},
+		{"malformed ttl", &model.WebPushConfig{Headers: &model.WebPushHeaders{TTL: "one day"}}, true},
+		{"high urgency", &model.WebPushConfig{Headers: &model.WebPushHeaders{Urgency: constant.UrgencyHigh}}, false},
+		{"unknown urgency", &model.WebPushConfig{Headers: &model.WebPushHeaders{Urgency: "urgent"}}, true},
+		{"http link", &model.WebPushConfig{HmsOptions: &model.HmsWebPushOption{Link: "http://www.huawei.com"}}, true},
+		{"relative link", &model.WebPushConfig{HmsOptions: &model.HmsWebPushOption{Link: "/news"}}, true},
+		{"unknown dir", &model.WebPushConfig{Notification: &model.WebPushNotification{Dir: "up"}}, true},
+		{"empty action", &model.WebPushConfig{Notification: &model.WebPushNotification{
+			Actions: []*model.WebPushAction{{Title: "Open"}},
+		}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verify.ValidateMessage(webPushMessage(tt.webPush))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
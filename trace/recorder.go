@@ -0,0 +1,261 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exchange is one http attempt as sent and received, see HmsTrace.GotExchange
+type Exchange struct {
+	Method        string
+	URL           string
+	RequestHeader http.Header
+	RequestBody   []byte
+	// Attempt is 1-based, any attempt above 1 is a retry
+	Attempt int
+
+	// Status is zero when no response came, Err tells why then
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	Duration       time.Duration
+	Err            error
+}
+
+// RecorderMaxBodySize is the largest request or response body a Recorder keeps, larger ones are left out
+// since a cut body can't be redacted reliably
+const RecorderMaxBodySize = 64 << 10
+
+// Record is an Exchange kept by a Recorder, with its secrets redacted
+type Record struct {
+	Time           time.Time     `json:"time"`
+	Method         string        `json:"method"`
+	URL            string        `json:"url"`
+	RequestHeader  http.Header   `json:"request_header,omitempty"`
+	RequestBody    string        `json:"request_body,omitempty"`
+	Attempt        int           `json:"attempt"`
+	Status         int           `json:"status,omitempty"`
+	ResponseHeader http.Header   `json:"response_header,omitempty"`
+	ResponseBody   string        `json:"response_body,omitempty"`
+	Duration       time.Duration `json:"duration"`
+	Error          string        `json:"error,omitempty"`
+	// Truncated is set when a body larger than RecorderMaxBodySize was replaced with a placeholder
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Recorder keeps the last requests of the contexts it instruments, for attaching to bug reports
+// Authorization and cookie headers, credentials in urls and the client secret and tokens in bodies are masked,
+// and bodies larger than RecorderMaxBodySize are left out, so its memory is bounded by its size
+type Recorder struct {
+	mu      sync.Mutex
+	records []*Record
+	next    int
+	full    bool
+}
+
+// NewRecorder returns a recorder keeping the last size requests, at least 1
+func NewRecorder(size int) *Recorder {
+	if size < 1 {
+		size = 1
+	}
+	return &Recorder{records: make([]*Record, size)}
+}
+
+// NewContext returns a context whose requests are recorded, on top of the hooks of the HmsTrace of ctx
+// Auth requests are only recorded with config.Config.DebugAuth, like they're only traced then
+func (r *Recorder) NewContext(ctx context.Context) context.Context {
+	t, _ := FromContext(ctx)
+	gotExchange := t.GotExchange
+	t.GotExchange = func(exchange *Exchange) {
+		r.record(exchange)
+		if gotExchange != nil {
+			gotExchange(exchange)
+		}
+	}
+	return NewContext(ctx, t)
+}
+
+// Records returns copies of the recorded requests, oldest first
+func (r *Recorder) Records() []*Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var records []*Record
+	if r.full {
+		records = append(records, r.records[r.next:]...)
+	}
+	records = append(records, r.records[:r.next]...)
+
+	copies := make([]*Record, len(records))
+	for i, record := range records {
+		c := *record
+		c.RequestHeader = record.RequestHeader.Clone()
+		c.ResponseHeader = record.ResponseHeader.Clone()
+		copies[i] = &c
+	}
+	return copies
+}
+
+// WriteTo writes the recorded requests to w as JSON lines, oldest first
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	encoder := json.NewEncoder(cw)
+	for _, record := range r.Records() {
+		if err := encoder.Encode(record); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+// Reset drops the recorded requests
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.records {
+		r.records[i] = nil
+	}
+	r.next = 0
+	r.full = false
+}
+
+func (r *Recorder) record(exchange *Exchange) {
+	record := &Record{
+		Time:           time.Now(),
+		Method:         exchange.Method,
+		URL:            redactURL(exchange.URL),
+		RequestHeader:  redactHeader(exchange.RequestHeader),
+		Attempt:        exchange.Attempt,
+		Status:         exchange.Status,
+		ResponseHeader: redactHeader(exchange.ResponseHeader),
+		Duration:       exchange.Duration,
+	}
+	if exchange.Err != nil {
+		record.Error = exchange.Err.Error()
+	}
+
+	var truncated bool
+	record.RequestBody, truncated = r.body(exchange.RequestBody)
+	record.Truncated = truncated
+	record.ResponseBody, truncated = r.body(exchange.ResponseBody)
+	record.Truncated = record.Truncated || truncated
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records[r.next] = record
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *Recorder) body(body []byte) (string, bool) {
+	if len(body) > RecorderMaxBodySize {
+		// the cut could fall inside a secret the patterns then wouldn't match
+		return fmt.Sprintf("<%d bytes body left out>", len(body)), true
+	}
+	return redactBody(string(body)), false
+}
+
+// redactedHeaders are the headers carrying credentials
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+func redactHeader(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+
+	redacted := header.Clone()
+	for _, name := range redactedHeaders {
+		values := redacted.Values(name)
+		for i, value := range values {
+			values[i] = redactCredential(value)
+		}
+	}
+	return redacted
+}
+
+// redactCredential masks a header value, keeping the scheme of an Authorization value, e.g. Bearer
+func redactCredential(value string) string {
+	if scheme, credential, ok := strings.Cut(value, " "); ok {
+		return scheme + " " + mask(credential)
+	}
+	return mask(value)
+}
+
+// secretFields are the form and json fields of the auth protocol carrying credentials
+var secretFields = []string{"client_secret", "access_token", "refresh_token"}
+
+var secretPatterns = func() []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, field := range secretFields {
+		patterns = append(patterns,
+			regexp.MustCompile(`("`+field+`"\s*:\s*")([^"]*)(")`),
+			regexp.MustCompile(`(^|&)(`+field+`=)([^&]*)`))
+	}
+	return patterns
+}()
+
+func redactBody(body string) string {
+	for i, pattern := range secretPatterns {
+		isJSON := i%2 == 0
+		body = pattern.ReplaceAllStringFunc(body, func(match string) string {
+			groups := pattern.FindStringSubmatch(match)
+			if isJSON {
+				return groups[1] + mask(groups[2]) + groups[3]
+			}
+			value, err := url.QueryUnescape(groups[3])
+			if err != nil {
+				value = groups[3]
+			}
+			return groups[1] + groups[2] + url.QueryEscape(mask(value))
+		})
+	}
+	return body
+}
+
+// mask masks a secret unless MaskSecret already did, e.g. the app secret of traced auth requests
+func mask(secret string) string {
+	if strings.HasPrefix(secret, "***") {
+		return secret
+	}
+	return MaskSecret(secret)
+}
+
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		}
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redactBody(u.RawQuery)
+	}
+	return u.String()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -1,9 +1,101 @@
 package trace
 
-var HmsTraceKey = struct{}{}
+import (
+	"context"
+	"fmt"
+)
+
+// traceKey is the context key of the HmsTrace, its own type so that no other package's key can collide with it
+type traceKey struct{}
+
+// HmsTraceKey is the context key of the HmsTrace, contexts made with
+// context.WithValue(ctx, HmsTraceKey, t) keep working with FromContext
+//
+// Deprecated: use NewContext and FromContext, HmsTraceKey will be removed in the next release
+var HmsTraceKey interface{} = traceKey{}
+
+// NewContext returns a context carrying t, the requests made with it report to its hooks
+func NewContext(ctx context.Context, t HmsTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// FromContext returns the HmsTrace carried by ctx, if any
+func FromContext(ctx context.Context) (HmsTrace, bool) {
+	t, ok := ctx.Value(traceKey{}).(HmsTrace)
+	return t, ok
+}
 
 type HmsTrace struct {
 	GotRequestBody    func([]byte)
 	GotResponseBody   func([]byte)
 	GotResponseStatus func(int)
+	// GotResponseAttempt receives the response status with the 1-based attempt it came from,
+	// any attempt above 1 is a retry
+	GotResponseAttempt func(status int, attempt int)
+	// GotMetadata receives the metadata attached to the request context with WithMetadata
+	// It's only called when there is metadata
+	GotMetadata func(Metadata)
+	// TokenInvalidated is called when the cached access token is dropped after failures consecutive
+	// sends were rejected for authorization reasons
+	TokenInvalidated func(failures int)
+	// MessageSuppressed is called with the request metadata, possibly nil, when a send is turned down
+	// by the ShouldSend hook of the client and never reaches HMS
+	MessageSuppressed func(Metadata)
+	// GotExchange is called after every http attempt with its request and response, secrets included,
+	// see Recorder for a redacting consumer
+	GotExchange func(*Exchange)
+	// DataSizeWarning is called with the request metadata when the data payload of a send is size bytes,
+	// above the configured share of limit, see config.Config.DataSizeWarningThreshold
+	DataSizeWarning func(size int, limit int, md Metadata)
+	// TokenRefreshed is called after every access token fetch with the metadata of the fetch, see TokenRefreshKey
+	TokenRefreshed func(md Metadata, err error)
+}
+
+// Metadata is caller data correlating a request with a logical message, e.g. a campaign or user id
+type Metadata map[string]string
+
+type metadataKey struct{}
+
+// WithMetadata returns a context carrying md merged over the metadata already in ctx
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	merged := Metadata{}
+	for k, v := range MetadataFrom(ctx) {
+		merged[k] = v
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, metadataKey{}, merged)
+}
+
+// ClientMessageIdKey is the metadata key of the id a caller gave its message for correlation
+const ClientMessageIdKey = "client_message_id"
+
+// ClientRequestIdKey is the metadata key of the id the client generated for a logical send,
+// sent to HMS in the X-Request-Id header
+const ClientRequestIdKey = "client_request_id"
+
+// TokenRefreshKey is the metadata key telling what made the client fetch an access token
+// An on demand fetch carries the metadata of the request needing the token, e.g. its ClientRequestIdKey,
+// a background one renews the token ahead of its expiry on behalf of no request and carries no other metadata
+const TokenRefreshKey = "token_refresh"
+
+// The values of TokenRefreshKey
+const (
+	TokenRefreshOnDemand   = "on_demand"
+	TokenRefreshBackground = "background"
+)
+
+// MetadataFrom returns the metadata attached to ctx, nil if there is none
+func MetadataFrom(ctx context.Context) Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+	return md
+}
+
+// MaskSecret hides a secret for logging, keeping only its length and last 4 characters
+func MaskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return fmt.Sprintf("***(%d)", len(secret))
+	}
+	return fmt.Sprintf("***%s(%d)", secret[len(secret)-4:], len(secret))
 }
@@ -0,0 +1,38 @@
+package trace_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dafanasiev/go-hms-push/trace"
+)
+
+func TestDeprecatedHmsTraceKey(t *testing.T) {
+	called := false
+	hooks := trace.HmsTrace{GotResponseStatus: func(int) { called = true }}
+
+	ctx := context.WithValue(context.Background(), trace.HmsTraceKey, hooks)
+	got, ok := trace.FromContext(ctx)
+	if !ok || got.GotResponseStatus == nil {
+		t.Fatal("FromContext doesn't find a trace stored under HmsTraceKey")
+	}
+	got.GotResponseStatus(200)
+	if !called {
+		t.Error("the hooks found aren't the stored ones")
+	}
+
+	if _, ok = trace.NewContext(context.Background(), hooks).Value(trace.HmsTraceKey).(trace.HmsTrace); !ok {
+		t.Error("a trace stored with NewContext isn't found under HmsTraceKey")
+	}
+}
+
+func TestFromContextWithoutTrace(t *testing.T) {
+	if _, ok := trace.FromContext(context.Background()); ok {
+		t.Error("FromContext found a trace in an empty context")
+	}
+	// an empty struct key of another package doesn't collide with the trace key
+	ctx := context.WithValue(context.Background(), struct{}{}, trace.HmsTrace{})
+	if _, ok := trace.FromContext(ctx); ok {
+		t.Error("FromContext found a trace stored under struct{}{}")
+	}
+}